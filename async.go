@@ -0,0 +1,114 @@
+package cflog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+)
+
+// asyncQueue hands entries to a background goroutine so Log does not wait on the logging API.
+type asyncQueue struct {
+	items   chan asyncItem
+	block   bool
+	dropped uint64
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// asyncItem is either entries to write or a flush marker to close once everything before it is written.
+type asyncItem struct {
+	entries []*loggingpb.LogEntry
+	flushed chan struct{}
+}
+
+func newAsyncQueue(size int, block bool) *asyncQueue {
+	return &asyncQueue{
+		items: make(chan asyncItem, size),
+		block: block,
+		done:  make(chan struct{}),
+	}
+}
+
+// run writes queued entries until the queue is stopped.
+func (q *asyncQueue) run(c Client) {
+	for {
+		select {
+		case item := <-q.items:
+			if item.flushed != nil {
+				close(item.flushed)
+				continue
+			}
+			if err := c.writeSync(context.Background(), item.entries); err != nil {
+				handleError(fmt.Errorf("could not write log entries: %w", err))
+			}
+		case <-q.done:
+			return
+		}
+	}
+}
+
+// enqueue queues entries, dropping them when the queue is stopped, or full unless it blocks.
+// It reports whether they were queued.
+func (q *asyncQueue) enqueue(entries []*loggingpb.LogEntry) bool {
+	// A select picks randomly between ready cases so check for a stopped queue first,
+	// otherwise entries could be queued with nothing left to write them.
+	select {
+	case <-q.done:
+		return q.drop(entries)
+	default:
+	}
+
+	item := asyncItem{entries: entries}
+	if q.block {
+		select {
+		case q.items <- item:
+			return true
+		case <-q.done:
+			return q.drop(entries)
+		}
+	}
+
+	select {
+	case q.items <- item:
+		return true
+	default:
+		return q.drop(entries)
+	}
+}
+
+// drop counts entries that were not queued and reports false.
+func (q *asyncQueue) drop(entries []*loggingpb.LogEntry) bool {
+	atomic.AddUint64(&q.dropped, uint64(len(entries)))
+	return false
+}
+
+// drain blocks until every entry queued before it has been written.
+func (q *asyncQueue) drain() {
+	flushed := make(chan struct{})
+	select {
+	case q.items <- asyncItem{flushed: flushed}:
+	case <-q.done:
+		return
+	}
+	select {
+	case <-flushed:
+	case <-q.done:
+	}
+}
+
+// stop ends the background goroutine. Entries still queued are not written.
+func (q *asyncQueue) stop() {
+	q.stopOnce.Do(func() { close(q.done) })
+}
+
+// Dropped returns how many entries asynchronous logging dropped because its queue was full or the client was closed.
+func (c Client) Dropped() uint64 {
+	if c.async == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&c.async.dropped)
+}
@@ -0,0 +1,57 @@
+package cflog
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAsync(t *testing.T) {
+	ctx := context.Background()
+	c, w := newFakeClient(WithAsync(10), WithBlockWhenFull(true))
+	defer c.async.stop()
+
+	for i := 0; i < 5; i++ {
+		if err := c.Info(ctx, "message"); err != nil {
+			t.Fatal("Log error", err)
+		}
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal("Flush error", err)
+	}
+	if n := len(w.entries()); n != 5 {
+		t.Fatal("Expected all entries written after flush", n)
+	}
+}
+
+func TestAsyncDrop(t *testing.T) {
+	ctx := context.Background()
+	// Without a writer goroutine running nothing leaves the queue.
+	c := newClient(WithAsync(2))
+
+	for i := 0; i < 5; i++ {
+		if err := c.Info(ctx, "message"); err != nil {
+			t.Fatal("Log error", err)
+		}
+	}
+	if d := c.Dropped(); d != 3 {
+		t.Fatal("Unexpected dropped count", d)
+	}
+}
+
+func TestAsyncStopped(t *testing.T) {
+	ctx := context.Background()
+	for _, block := range []bool{false, true} {
+		h := &countingHook{}
+		c := newClient(WithAsync(10), WithBlockWhenFull(block), WithHook(h))
+		c.async.stop()
+
+		for i := 0; i < 20; i++ {
+			if err := c.Info(ctx, "message"); err != nil {
+				t.Fatal("Log error", err)
+			}
+		}
+		if d := c.Dropped(); d != 20 || len(h.dropped) != 20 || len(c.async.items) != 0 {
+			t.Fatal("Unexpected drops", block, d, len(h.dropped), len(c.async.items))
+		}
+	}
+}
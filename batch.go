@@ -0,0 +1,159 @@
+package cflog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// batcher buffers entries so they can be written in a single WriteLogEntries request.
+type batcher struct {
+	size     int
+	interval time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries []*loggingpb.LogEntry
+	oldest  time.Time
+
+	done     chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func newBatcher(size int, interval time.Duration, now func() time.Time) *batcher {
+	return &batcher{size: size, interval: interval, now: now, done: make(chan struct{})}
+}
+
+// startFlusher writes the buffer every flush interval in the background until the batcher
+// is stopped or ctx is done.
+func (b *batcher) startFlusher(ctx context.Context, c Client) {
+	b.stopped = make(chan struct{})
+	go func() {
+		defer close(b.stopped)
+		ticker := time.NewTicker(b.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if entries := b.take(); len(entries) > 0 {
+					if err := c.send(context.Background(), entries); err != nil {
+						handleError(fmt.Errorf("could not write log entries: %w", err))
+					}
+				}
+			case <-b.done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// stop ends the background flusher, waiting for a write in progress to finish.
+func (b *batcher) stop() {
+	b.stopOnce.Do(func() { close(b.done) })
+	if b.stopped != nil {
+		<-b.stopped
+	}
+}
+
+// add buffers entries and returns the whole buffer once it should be written.
+// The buffer is ready when it reaches the batch size or its oldest entry is older than the flush interval.
+func (b *batcher) add(entries []*loggingpb.LogEntry) []*loggingpb.LogEntry {
+	now := b.now()
+	for _, e := range entries {
+		// Entries are received later than they were logged, so keep the time they were logged.
+		if e.Timestamp == nil {
+			e.Timestamp = timestamppb.New(now)
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.entries) == 0 {
+		b.oldest = now
+	}
+	b.entries = append(b.entries, entries...)
+
+	full := b.size > 1 && len(b.entries) >= b.size
+	stale := b.interval > 0 && now.Sub(b.oldest) >= b.interval
+	if !full && !stale {
+		return nil
+	}
+	return b.takeLocked()
+}
+
+// take empties the buffer and returns what was in it.
+func (b *batcher) take() []*loggingpb.LogEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.takeLocked()
+}
+
+func (b *batcher) takeLocked() []*loggingpb.LogEntry {
+	entries := b.entries
+	b.entries = nil
+	return entries
+}
+
+// Entry is a log to write as part of a batch with LogEntries.
+type Entry struct {
+	Severity Severity
+	Payload  interface{}
+	Labels   map[string]string
+}
+
+// ErrNoEntries is returned by LogEntries when it is given no entries.
+var ErrNoEntries = errors.New("no entries to log")
+
+// LogBatch creates an entry for each payload and writes them together in a single request.
+// It is more efficient than calling Log in a loop. If any payload cannot be converted nothing is written.
+func (c Client) LogBatch(ctx context.Context, severity Severity, payloads []interface{}) error {
+	entries := make([]Entry, len(payloads))
+	for i, payload := range payloads {
+		entries[i] = Entry{Severity: severity, Payload: payload}
+	}
+	return c.writeEntries(ctx, entries)
+}
+
+// LogEntries writes entries of any severity together in a single request.
+// Labels of each entry override the client's default labels. If any payload cannot be converted
+// nothing is written.
+func (c Client) LogEntries(ctx context.Context, entries []Entry) error {
+	if len(entries) == 0 {
+		return ErrNoEntries
+	}
+	return c.writeEntries(ctx, entries)
+}
+
+// writeEntries builds and writes entries in a single request, skipping any the client filters out.
+func (c Client) writeEntries(ctx context.Context, entries []Entry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	built := make([]*loggingpb.LogEntry, 0, len(entries))
+	for i, e := range entries {
+		if !c.keep(e.Severity) {
+			c.onDrop(e.Severity)
+			continue
+		}
+		entry, err := c.BuildEntry(ctx, e.Severity, e.Payload)
+		if err != nil {
+			return fmt.Errorf("entry %d: %w", i, err)
+		}
+		entry.Labels = mergeLabels(entry.Labels, e.Labels)
+		built = append(built, entry)
+	}
+	if len(built) == 0 {
+		return nil
+	}
+	return c.write(ctx, built...)
+}
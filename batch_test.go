@@ -0,0 +1,227 @@
+package cflog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	gax "github.com/googleapis/gax-go/v2"
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeWriter records the requests written to it.
+type fakeWriter struct {
+	mu       sync.Mutex
+	requests []*loggingpb.WriteLogEntriesRequest
+	// errs are returned by the next writes, one each, without recording the request.
+	errs []error
+}
+
+func (w *fakeWriter) WriteLogEntries(ctx context.Context, req *loggingpb.WriteLogEntriesRequest, opts ...gax.CallOption) (*loggingpb.WriteLogEntriesResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.errs) > 0 {
+		err := w.errs[0]
+		w.errs = w.errs[1:]
+		return nil, err
+	}
+	w.requests = append(w.requests, req)
+	return &loggingpb.WriteLogEntriesResponse{}, nil
+}
+
+func (w *fakeWriter) entries() []*loggingpb.LogEntry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var entries []*loggingpb.LogEntry
+	for _, req := range w.requests {
+		entries = append(entries, req.Entries...)
+	}
+	return entries
+}
+
+func newFakeClient(opts ...Option) (Client, *fakeWriter) {
+	w := &fakeWriter{}
+	return NewClientWithWriter(w, opts...), w
+}
+
+func TestBatchSize(t *testing.T) {
+	ctx := context.Background()
+	c, w := newFakeClient(WithBatchSize(3))
+
+	for i := 0; i < 4; i++ {
+		if err := c.Info(ctx, "message"); err != nil {
+			t.Fatal("Log error", err)
+		}
+	}
+	if len(w.requests) != 1 || len(w.requests[0].Entries) != 3 {
+		t.Fatal("Expected one request of three entries", w.requests)
+	}
+	if w.requests[0].Entries[0].Timestamp == nil {
+		t.Fatal("Expected batched entries to be timestamped")
+	}
+
+	if err := c.Flush(); err != nil {
+		t.Fatal("Flush error", err)
+	}
+	if len(w.requests) != 2 || len(w.requests[1].Entries) != 1 {
+		t.Fatal("Expected flush to write the remaining entry", w.requests)
+	}
+
+	if err := c.Flush(); err != nil {
+		t.Fatal("Flush error", err)
+	}
+	if len(w.requests) != 2 {
+		t.Fatal("Expected empty flush to not write", w.requests)
+	}
+}
+
+func TestFlushInterval(t *testing.T) {
+	ctx := context.Background()
+	c, w := newFakeClient(WithFlushInterval(50 * time.Millisecond))
+
+	if err := c.Info(ctx, "first"); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if entries := w.entries(); len(entries) != 0 {
+		t.Fatal("Expected entry to be buffered", entries)
+	}
+
+	// The background flusher writes the buffer without another entry being logged.
+	deadline := time.Now().Add(time.Second)
+	for len(w.entries()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if entries := w.entries(); len(entries) != 1 {
+		t.Fatal("Expected stale buffer to be written", entries)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal("Close error", err)
+	}
+	select {
+	case <-c.batch.stopped:
+	default:
+		t.Fatal("Expected the flusher to stop on Close")
+	}
+}
+
+func TestFlushIntervalContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c, err := NewClient(ctx, WithOutput(io.Discard), WithFlushInterval(time.Hour))
+	if err != nil {
+		t.Fatal("Client error", err)
+	}
+	cancel()
+
+	select {
+	case <-c.batch.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the flusher to stop when the context is done")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal("Close error", err)
+	}
+}
+
+func TestLogBatch(t *testing.T) {
+	ctx := context.Background()
+	c, w := newFakeClient()
+
+	if err := c.LogBatch(ctx, SeverityInfo, []interface{}{"a", map[string]interface{}{"b": 1}, "c"}); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if len(w.requests) != 1 || len(w.requests[0].Entries) != 3 {
+		t.Fatal("Expected one request of three entries", w.requests)
+	}
+	if w.requests[0].Entries[1].GetJsonPayload() == nil {
+		t.Fatal("Expected each entry to get its own payload", w.requests[0].Entries)
+	}
+
+	if err := c.LogBatch(ctx, SeverityInfo, []interface{}{"a", func() {}}); err == nil {
+		t.Fatal("Expected error for a payload that cannot be converted")
+	}
+	if len(w.requests) != 1 {
+		t.Fatal("Expected nothing written", w.requests)
+	}
+}
+
+func TestLogEntries(t *testing.T) {
+	ctx := context.Background()
+	c, w := newFakeClient(WithDefaultLabels(map[string]string{"k": "default"}))
+
+	if err := c.LogEntries(ctx, nil); err != ErrNoEntries {
+		t.Fatal("Expected ErrNoEntries", err)
+	}
+
+	err := c.LogEntries(ctx, []Entry{
+		{Severity: SeverityInfo, Payload: "info"},
+		{Severity: SeverityError, Payload: "error", Labels: map[string]string{"k": "override"}},
+	})
+	if err != nil {
+		t.Fatal("Log error", err)
+	}
+	if len(w.requests) != 1 {
+		t.Fatal("Expected one request", w.requests)
+	}
+	entries := w.requests[0].Entries
+	if Severity(entries[0].Severity) != SeverityInfo || Severity(entries[1].Severity) != SeverityError {
+		t.Fatal("Unexpected severities", entries)
+	}
+	if entries[0].Labels["k"] != "default" || entries[1].Labels["k"] != "override" {
+		t.Fatal("Unexpected labels", entries[0].Labels, entries[1].Labels)
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	c, w := newFakeClient(WithBatchSize(2), WithClock(func() time.Time { return now }))
+
+	ctx := context.Background()
+	c.Info(ctx, "a")
+	c.Info(ctx, "b")
+	for _, e := range w.entries() {
+		if !e.Timestamp.AsTime().Equal(now) {
+			t.Fatal("Unexpected timestamp", e.Timestamp.AsTime())
+		}
+	}
+}
+
+func TestPartialSuccess(t *testing.T) {
+	c, w := newFakeClient(WithPartialSuccess(true))
+	st, err := status.New(codes.InvalidArgument, "some entries failed").WithDetails(&loggingpb.WriteLogEntriesPartialErrors{
+		LogEntryErrors: map[int32]*spb.Status{1: {Code: int32(codes.InvalidArgument), Message: "bad label"}},
+	})
+	if err != nil {
+		t.Fatal("Status error", err)
+	}
+	w.errs = []error{st.Err()}
+
+	err = c.LogBatch(context.Background(), SeverityInfo, []interface{}{"a", "b", "c"})
+	var partial *PartialWriteError
+	if !errors.As(err, &partial) {
+		t.Fatal("Expected a partial write error", err)
+	}
+	if partial.Total != 3 || len(partial.Rejected) != 1 || status.Code(partial.Rejected[1]) != codes.InvalidArgument {
+		t.Fatal("Unexpected rejected entries", partial.Rejected)
+	}
+	if expected := "1 of 3 log entries rejected; entry 1: rpc error: code = InvalidArgument desc = bad label"; err.Error() != expected {
+		t.Fatal("Unexpected message", err)
+	}
+
+	if err := c.Info(context.Background(), "d"); err != nil || !w.requests[0].PartialSuccess {
+		t.Fatal("Expected partial success to be requested", err)
+	}
+
+	// Without partial success errors are returned as they are.
+	c, w = newFakeClient()
+	w.errs = []error{st.Err()}
+	if err := c.Info(context.Background(), "a"); errors.As(err, &partial) {
+		t.Fatal("Unexpected partial write error", err)
+	}
+}
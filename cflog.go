@@ -3,141 +3,767 @@ package cflog
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
-	"strings"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"cloud.google.com/go/logging/apiv2"
-	_struct "github.com/golang/protobuf/ptypes/struct"
-	"github.com/micro/protobuf/jsonpb"
+	gax "github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/option"
 	"google.golang.org/genproto/googleapis/api/monitoredres"
 	"google.golang.org/genproto/googleapis/logging/type"
 	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
-)
-
-// Severity is a wrapper around an int for log severity.
-type Severity ltype.LogSeverity
-
-// Using the log packages severity.
-// https://godoc.org/google.golang.org/genproto/googleapis/logging/type#LogSeverity
-const (
-	SeverityDefault   = Severity(ltype.LogSeverity_DEFAULT)
-	SeverityDebug     = Severity(ltype.LogSeverity_DEBUG)
-	SeverityInfo      = Severity(ltype.LogSeverity_INFO)
-	SeverityNotice    = Severity(ltype.LogSeverity_NOTICE)
-	SeverityWarning   = Severity(ltype.LogSeverity_WARNING)
-	SeverityError     = Severity(ltype.LogSeverity_ERROR)
-	SeverityCritical  = Severity(ltype.LogSeverity_CRITICAL)
-	SeverityAlert     = Severity(ltype.LogSeverity_ALERT)
-	SeverityEmergency = Severity(ltype.LogSeverity_EMERGENCY)
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // Client holds a logging client and the resources needed for logging.
 type Client struct {
 	client               *logging.Client
+	writer               EntryWriter
+	projectID            string
+	logID                string
 	logName              string
 	logMonitoredResource *monitoredres.MonitoredResource
+	resource             resourceFunc
+	resourceLabels       map[string]string
+	labels               map[string]string
+	traceExtractor       TraceExtractor
+	sourceLocation       bool
+	callerSkip           string
+	callDepth            int
+	insertID             func() string
+	batchSize            int
+	flushInterval        time.Duration
+	batch                *batcher
+	asyncSize            int
+	asyncBlock           bool
+	async                *asyncQueue
+	localWriter          EntryWriter
+	serviceName          string
+	serviceVersion       string
+	minSeverity          Severity
+	sampler              func(Severity) bool
+	retryAttempts        int
+	retryDelay           time.Duration
+	writeTimeout         time.Duration
+	closeOnce            *sync.Once
+	redacted             map[string]bool
+	maxEntrySize         int
+	repanic              bool
+	clientOptions        []option.ClientOption
+	hook                 Hook
+	now                  func() time.Time
+	metadataDetection    bool
+	partialSuccess       bool
+	messageKey           string
+	specialFields        bool
+	stringers            bool
+	requestHook          func(*loggingpb.WriteLogEntriesRequest)
+	strictLabels         bool
+}
+
+// Logger writes logs and is satisfied by Client.
+// Accept it instead of a Client so tests can supply a fake.
+type Logger interface {
+	Log(ctx context.Context, severity Severity, payload interface{}) error
 }
 
+var _ Logger = Client{}
+
+// EntryWriter writes log entries to the logging API and is satisfied by *logging.Client.
+// Implement it to capture entries in tests without network access.
+type EntryWriter interface {
+	WriteLogEntries(ctx context.Context, req *loggingpb.WriteLogEntriesRequest, opts ...gax.CallOption) (*loggingpb.WriteLogEntriesResponse, error)
+}
+
+// defaultLogID is the log that Cloud Functions write to.
+const defaultLogID = "cloudfunctions.googleapis.com/cloud-functions"
+
+// defaultMessageKey is the JSON payload field the Logs Explorer shows as the summary of an entry.
+const defaultMessageKey = "message"
+
 // NewClient creates a client for writing logs using environment variable.
 // Use this if you want to want full control over the client.
+// Options are applied after the environment defaults are read so they take precedence.
 // https://cloud.google.com/functions/docs/env-var
-func NewClient(ctx context.Context) (Client, error) {
-	c := Client{}
-	client, err := logging.NewClient(ctx)
+func NewClient(ctx context.Context, opts ...Option) (Client, error) {
+	c := newClient(opts...)
+	if c.localWriter != nil {
+		c.setWriter(ctx, c.localWriter)
+		return c, nil
+	}
+	if c.resource == nil && envResource(c.projectID) == nil {
+		detectCtx, cancel := context.WithTimeout(ctx, metadataTimeout)
+		if r := gceLookup(detectCtx, c.projectID); r != nil {
+			c.setResource(r)
+		}
+		cancel()
+	}
+
+	client, err := logging.NewClient(ctx, c.clientOptions...)
 	if err != nil {
 		return c, err
 	}
 
 	c.client = client
-	c.logName = fmt.Sprintf("projects/%s/logs/cloudfunctions.googleapis.com%scloud-functions", os.Getenv("GCP_PROJECT"), "%2F")
-	c.logMonitoredResource = &monitoredres.MonitoredResource{
-		Type: "cloud_function",
-		Labels: map[string]string{
-			"function_name": os.Getenv("FUNCTION_NAME"),
-			"project_id":    os.Getenv("GCP_PROJECT"),
-			"region":        os.Getenv("FUNCTION_REGION"),
-		},
-	}
-
+	c.setWriter(ctx, client)
 	return c, nil
 }
 
-// Close will close the underlying client.
-func (c Client) Close() error {
-	return c.client.Close()
+// NewClientWithLogging creates a client configured like NewClient that writes with an existing logging client,
+// such as one created with custom client options. Close closes the logging client too.
+func NewClientWithLogging(client *logging.Client, opts ...Option) Client {
+	c := newClient(opts...)
+	c.client = client
+	c.setWriter(context.Background(), client)
+	return c
+}
+
+// Underlying returns the logging client c writes with, for calls cflog does not wrap such as listing entries.
+// It is nil unless c was created by NewClient connecting to the logging API or by NewClientWithLogging.
+// Closing c closes it, so do not close it separately or use it after closing c.
+func (c Client) Underlying() *logging.Client {
+	return c.client
+}
+
+// NewClientWithWriter creates a client configured like NewClient that writes its entries to w
+// instead of connecting to the logging API.
+func NewClientWithWriter(w EntryWriter, opts ...Option) Client {
+	c := newClient(opts...)
+	c.setWriter(context.Background(), w)
+	return c
+}
+
+// NewNopClient creates a client that discards every entry, for tests and local runs without credentials.
+func NewNopClient() Client {
+	return NewClientWithWriter(nopWriter{})
+}
+
+// With returns a copy of the client that adds labels to every entry and groups them under the trace from
+// an X-Cloud-Trace-Context header value, such as for the duration of a request. The labels are merged over
+// the client's default labels and a valid trace takes precedence over any found in the context.
+// The copy shares the connection and any buffered entries of c, so closing either closes both.
+func (c Client) With(labels map[string]string, trace string) Client {
+	c.labels = mergeLabels(c.labels, labels)
+	if t := ParseTraceHeader(trace); t.TraceID != "" {
+		c.traceExtractor = func(context.Context) Trace { return t }
+	}
+	return c
+}
+
+// nopWriter is an EntryWriter that discards entries.
+type nopWriter struct{}
+
+func (nopWriter) WriteLogEntries(context.Context, *loggingpb.WriteLogEntriesRequest, ...gax.CallOption) (*loggingpb.WriteLogEntriesResponse, error) {
+	return &loggingpb.WriteLogEntriesResponse{}, nil
 }
 
-func setEntryPayload(entry *loggingpb.LogEntry, in interface{}) error {
-	var s string
-	switch v := in.(type) {
-	case string:
-		s = v
-	case []byte:
-		s = string(v)
+// newClient builds the configuration of a Client from the environment and options without connecting.
+func newClient(opts ...Option) Client {
+	c := Client{
+		projectID:      firstEnv("GCP_PROJECT", "GOOGLE_CLOUD_PROJECT"),
+		logID:          defaultLogID,
+		serviceName:    firstEnv("K_SERVICE", "FUNCTION_NAME"),
+		serviceVersion: os.Getenv("K_REVISION"),
+		traceExtractor: TraceFromContext,
+		closeOnce:      &sync.Once{},
+		now:            time.Now,
+		messageKey:     defaultMessageKey,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if cw, ok := c.localWriter.(*consoleWriter); ok {
+		cw.now, cw.messageKey = c.now, c.messageField()
+	}
+
+	if c.metadataDetection && c.projectID == "" {
+		c.projectID = metadataLookup().projectID
+	}
+	c.logName = logName(c.projectID, c.logID)
+	switch {
+	case c.resource != nil:
+		c.setResource(c.resource(c.projectID))
+	case envResource(c.projectID) != nil:
+		c.setResource(envResource(c.projectID))
 	default:
-		if v == nil {
-			break
-		}
-		data, err := json.Marshal(v)
-		if err != nil {
-			return err
+		// NewClient looks for a Compute Engine resource, which needs the metadata server.
+		c.setResource(globalResource(c.projectID))
+	}
+	if c.batchSize > 1 || c.flushInterval > 0 {
+		c.batch = newBatcher(c.batchSize, c.flushInterval, c.now)
+	}
+	if c.asyncSize > 0 {
+		c.async = newAsyncQueue(c.asyncSize, c.asyncBlock)
+	}
+	return c
+}
+
+// setResource sets the resource entries are attributed to with the labels of WithResourceLabels
+// and WithMetadataDetection added.
+func (c *Client) setResource(r *monitoredres.MonitoredResource) {
+	c.logMonitoredResource = r
+	resourceLabels := c.resourceLabels
+	if c.metadataDetection {
+		resourceLabels = mergeLabels(metadataResourceLabels(r), resourceLabels)
+	}
+	if len(resourceLabels) > 0 {
+		// Copy the resource so one passed to WithMonitoredResource is not changed.
+		c.logMonitoredResource = &monitoredres.MonitoredResource{
+			Type:   r.GetType(),
+			Labels: mergeLabels(r.GetLabels(), resourceLabels),
 		}
-		s = string(data)
 	}
+}
+
+// setWriter sets where entries are written and starts any background writing,
+// with the interval flusher running until ctx is done.
+func (c *Client) setWriter(ctx context.Context, w EntryWriter) {
+	c.writer = w
+	if c.async != nil {
+		go c.async.run(*c)
+	}
+	if c.batch != nil && c.batch.interval > 0 {
+		c.batch.startFlusher(ctx, *c)
+	}
+}
 
-	if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
-		var payload _struct.Struct
-		if err := jsonpb.UnmarshalString(s, &payload); err == nil {
-			entry.Payload = &loggingpb.LogEntry_JsonPayload{JsonPayload: &payload}
-			return nil
+// firstEnv returns the first non-empty environment variable of the keys given.
+// Newer runtimes set GOOGLE_CLOUD_PROJECT and K_SERVICE instead of GCP_PROJECT and FUNCTION_NAME.
+func firstEnv(keys ...string) string {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			return v
 		}
 	}
+	return ""
+}
+
+// logName builds the full resource name of a log from its project and log ID.
+func logName(projectID, logID string) string {
+	return fmt.Sprintf("projects/%s/logs/%s", projectID, url.PathEscape(logID))
+}
 
-	entry.Payload = &loggingpb.LogEntry_TextPayload{TextPayload: s}
+// Flush blocks until all pending entries have been written.
+// Call it before returning from short-lived functions so entries are not lost when the instance is frozen.
+func (c Client) Flush() error {
+	if c.async != nil {
+		c.async.drain()
+	}
+	if c.batch == nil {
+		return nil
+	}
+	if entries := c.batch.take(); len(entries) > 0 {
+		return c.send(context.Background(), entries)
+	}
 	return nil
 }
 
+// Close flushes pending entries and then closes the underlying client.
+// It is safe to call more than once; calls after the first do nothing and return nil.
+func (c Client) Close() error {
+	if c.closeOnce == nil {
+		// A zero value Client has nothing to close more than once.
+		return c.close()
+	}
+	var err error
+	c.closeOnce.Do(func() { err = c.close() })
+	return err
+}
+
+func (c Client) close() error {
+	if c.batch != nil {
+		c.batch.stop()
+	}
+	flushErr := c.Flush()
+	if c.async != nil {
+		c.async.stop()
+	}
+	if c.client == nil {
+		return flushErr
+	}
+	if err := c.client.Close(); err != nil {
+		return err
+	}
+	return flushErr
+}
+
 // Log creates a log using the payload given.
 // Payload should be either a string or a struct that can marshal to JSON.
 func (c Client) Log(ctx context.Context, severity Severity, payload interface{}) error {
+	return c.log(ctx, severity, payload, nil)
+}
+
+// Logf creates a log with a text payload formatted like fmt.Sprintf.
+func (c Client) Logf(ctx context.Context, severity Severity, format string, args ...interface{}) error {
+	return c.Log(ctx, severity, fmt.Sprintf(format, args...))
+}
+
+// LogFields creates a log with a JSON payload of the fields given and the message under "message",
+// or the key set with WithMessageKey.
+func (c Client) LogFields(ctx context.Context, severity Severity, message string, fields map[string]interface{}) error {
+	payload := make(map[string]interface{}, len(fields)+1)
+	for k, v := range fields {
+		payload[k] = v
+	}
+	payload[c.messageField()] = message
+	return c.Log(ctx, severity, payload)
+}
+
+// messageField is the JSON payload field messages are written under.
+func (c Client) messageField() string {
+	if c.messageKey == "" {
+		return defaultMessageKey
+	}
+	return c.messageKey
+}
+
+// LogKV creates a log like LogFields with fields from alternating keys and values, e.g.
+// c.LogKV(ctx, SeverityInfo, "user created", "user_id", id, "plan", plan).
+// A key that is not a string, or a final key without a value, is kept under "!BADKEY".
+func (c Client) LogKV(ctx context.Context, severity Severity, message string, kvs ...interface{}) error {
+	return c.LogFields(ctx, severity, message, kvFields(kvs))
+}
+
+// badKey holds malformed key value pairs the same way log/slog does.
+const badKey = "!BADKEY"
+
+// kvFields converts alternating keys and values into fields.
+func kvFields(kvs []interface{}) map[string]interface{} {
+	fields := make(map[string]interface{}, len(kvs)/2)
+	for i := 0; i < len(kvs); i++ {
+		key, ok := kvs[i].(string)
+		if !ok || i == len(kvs)-1 {
+			fields[badKey] = kvs[i]
+			continue
+		}
+		fields[key] = kvs[i+1]
+		i++
+	}
+	return fields
+}
+
+// LogWithLabels creates a log like Log with labels added to the entry.
+// These are separate from the monitored resource labels and are searchable in the Logs Explorer.
+// They override any default labels of the client and labels added to the context by WithLabels with the same key.
+func (c Client) LogWithLabels(ctx context.Context, severity Severity, payload interface{}, labels map[string]string) error {
+	return c.log(ctx, severity, payload, func(entry *loggingpb.LogEntry) error {
+		entry.Labels = mergeLabels(entry.Labels, labels)
+		return nil
+	})
+}
+
+// LogAt creates a log like Log with the entry's timestamp set to t instead of when it is received.
+// Use it when replaying or forwarding events so they are ordered by the time they happened.
+func (c Client) LogAt(ctx context.Context, severity Severity, payload interface{}, t time.Time) error {
+	return c.log(ctx, severity, payload, func(entry *loggingpb.LogEntry) error {
+		entry.Timestamp = timestamppb.New(t)
+		return entry.Timestamp.CheckValid()
+	})
+}
+
+// timedDurationKey holds the time taken in the payload of LogTimed entries.
+const timedDurationKey = "duration"
+
+// LogTimed creates a log like Log with the time since start added to the payload under "duration", at the
+// severity of the longest threshold the time taken reached, or Info if it reached none. For example
+// thresholds of {time.Second: SeverityWarning, 5 * time.Second: SeverityError} log an operation that took
+// 2s as Warning. A text payload becomes a JSON payload with the text under the message key.
+func (c Client) LogTimed(ctx context.Context, start time.Time, thresholds map[time.Duration]Severity, payload interface{}) error {
+	elapsed := c.now().Sub(start)
+	severity := SeverityInfo
+	var reached time.Duration
+	found := false
+	for d, s := range thresholds {
+		if elapsed >= d && (!found || d > reached) {
+			severity, reached, found = s, d, true
+		}
+	}
+	return c.log(ctx, severity, payload, func(entry *loggingpb.LogEntry) error {
+		return addEntryFields(entry, map[string]interface{}{timedDurationKey: elapsed}, c.messageField())
+	})
+}
+
+// LogWithInsertID creates a log like Log with the entry's insertId set.
+// Cloud Logging drops entries with the same insertId and timestamp as an earlier entry in the same log,
+// so reusing an ID derived from the triggering event keeps retried invocations from logging twice.
+// Entries that are not retries must use distinct IDs or they will be dropped as duplicates.
+func (c Client) LogWithInsertID(ctx context.Context, severity Severity, payload interface{}, insertID string) error {
+	return c.log(ctx, severity, payload, func(entry *loggingpb.LogEntry) error {
+		entry.InsertId = insertID
+		return nil
+	})
+}
+
+// LogTo creates a log like Log written to the log with the ID given instead of the client's, in the same project,
+// so one client can keep the entries of several components in separate logs.
+func (c Client) LogTo(ctx context.Context, logID string, severity Severity, payload interface{}) error {
+	return c.log(ctx, severity, payload, func(entry *loggingpb.LogEntry) error {
+		entry.LogName = logName(c.projectID, logID)
+		return nil
+	})
+}
+
+// LogOperation creates a log like Log with the entry's operation set so the Logs Explorer groups
+// the entries of a long running task by its ID and producer, marking the first and last of them.
+// A nil operation leaves the field out.
+func (c Client) LogOperation(ctx context.Context, severity Severity, payload interface{}, op *loggingpb.LogEntryOperation) error {
+	return c.log(ctx, severity, payload, func(entry *loggingpb.LogEntry) error {
+		entry.Operation = op
+		return nil
+	})
+}
+
+// log builds an entry, lets set adjust it, and writes it.
+// Entries below the client's minimum severity or dropped by its sampler are skipped before the payload is converted.
+// A done ctx returns its error without building the entry since the write could not succeed.
+func (c Client) log(ctx context.Context, severity Severity, payload interface{}, set func(*loggingpb.LogEntry) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !c.keep(severity) {
+		c.onDrop(severity)
+		return nil
+	}
+
+	entry, err := c.BuildEntry(ctx, severity, payload)
+	if err != nil {
+		return err
+	}
+	if set != nil {
+		if err := set(entry); err != nil {
+			return err
+		}
+	}
+	return c.write(ctx, entry)
+}
+
+// keep reports whether an entry of the severity passes the client's minimum severity and sampler,
+// and logging has not been disabled.
+func (c Client) keep(severity Severity) bool {
+	if disabled.Load() || int32(severity) < int32(c.minSeverity) {
+		return false
+	}
+	return c.sampler == nil || c.sampler(severity)
+}
+
+// BuildEntry returns the entry Log would write for the payload without writing it,
+// for inspecting the severity, payload, labels, and resource in tests and tooling.
+// Any fields, labels, and trace found in the context are attached to the entry.
+// The client's minimum severity and sampling are not applied.
+func (c Client) BuildEntry(ctx context.Context, severity Severity, payload interface{}) (*loggingpb.LogEntry, error) {
 	// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry
 	entry := &loggingpb.LogEntry{
 		LogName:  c.logName,
 		Resource: c.logMonitoredResource,
 		Severity: ltype.LogSeverity(severity),
+		Labels:   mergeLabels(c.labels, LabelsFromContext(ctx)),
 	}
-	if err := setEntryPayload(entry, payload); err != nil {
-		return err
+	if c.stringers {
+		payload = stringerPayload(payload)
+	}
+	if err := setPayload(entry, payload, c.messageField()); err != nil {
+		return nil, err
+	}
+	if c.specialFields {
+		c.promoteSpecialFields(entry)
+	}
+	if fields := FieldsFromContext(ctx); len(fields) > 0 {
+		if err := addEntryFields(entry, fields, c.messageField()); err != nil {
+			return nil, err
+		}
+	}
+	if len(c.redacted) > 0 {
+		redactEntry(entry, c.redacted)
+	}
+	if c.traceExtractor != nil && entry.Trace == "" {
+		c.setEntryTrace(entry, c.traceExtractor(ctx))
+	}
+	if c.sourceLocation {
+		entry.SourceLocation = callerLocation(c.callerSkip, c.callDepth)
+	}
+	if c.insertID != nil {
+		entry.InsertId = c.insertID()
+	}
+	return entry, nil
+}
+
+// mergeLabels combines label maps into a new map with later maps taking precedence.
+// It returns nil if there are no labels so entries without labels omit the field.
+func mergeLabels(maps ...map[string]string) map[string]string {
+	var merged map[string]string
+	for _, m := range maps {
+		for k, v := range m {
+			if merged == nil {
+				merged = make(map[string]string)
+			}
+			merged[k] = v
+		}
 	}
+	return merged
+}
+
+// write sends entries to the logging API, or queues them when asynchronous logging is enabled.
+func (c Client) write(ctx context.Context, entries ...*loggingpb.LogEntry) error {
+	for _, e := range entries {
+		if err := fitLabels(e, c.strictLabels); err != nil {
+			return err
+		}
+	}
+	if c.maxEntrySize > 0 {
+		for _, e := range entries {
+			if err := fitEntry(e, c.maxEntrySize); err != nil {
+				return err
+			}
+		}
+	}
+	if c.async != nil {
+		if !c.async.enqueue(entries) {
+			for _, e := range entries {
+				c.onDrop(Severity(e.Severity))
+			}
+		}
+		return nil
+	}
+	return c.writeSync(ctx, entries)
+}
+
+// writeSync sends entries to the logging API, or buffers them when batching is enabled.
+func (c Client) writeSync(ctx context.Context, entries []*loggingpb.LogEntry) error {
+	if c.batch != nil {
+		if ready := c.batch.add(entries); len(ready) > 0 {
+			return c.send(ctx, ready)
+		}
+		return nil
+	}
+	return c.send(ctx, entries)
+}
 
-	req := &loggingpb.WriteLogEntriesRequest{Entries: []*loggingpb.LogEntry{entry}}
-	if _, err := c.client.WriteLogEntries(ctx, req); err != nil {
+// send writes entries to the logging API in a single request.
+func (c Client) send(ctx context.Context, entries []*loggingpb.LogEntry) error {
+	req := &loggingpb.WriteLogEntriesRequest{Entries: entries, PartialSuccess: c.partialSuccess}
+	if c.requestHook != nil {
+		c.requestHook(req)
+	}
+	err := retry(ctx, c.retryAttempts, c.retryDelay, func() error {
+		writeCtx := ctx
+		if c.writeTimeout > 0 {
+			var cancel context.CancelFunc
+			writeCtx, cancel = context.WithTimeout(ctx, c.writeTimeout)
+			defer cancel()
+		}
+		_, err := c.writer.WriteLogEntries(writeCtx, req)
 		return err
+	})
+	if err != nil && c.partialSuccess {
+		err = partialWriteError(err, len(req.Entries))
 	}
+	if err != nil {
+		c.onError(err)
+		return err
+	}
+	c.onWrite(len(req.Entries))
 	return nil
 }
 
-var singleton Client
+// Debug calls Log with the severity set to Debug.
+func (c Client) Debug(ctx context.Context, payload interface{}) error {
+	return c.Log(ctx, SeverityDebug, payload)
+}
+
+// Info calls Log with the severity set to Info.
+func (c Client) Info(ctx context.Context, payload interface{}) error {
+	return c.Log(ctx, SeverityInfo, payload)
+}
+
+// Notice calls Log with the severity set to Notice.
+func (c Client) Notice(ctx context.Context, payload interface{}) error {
+	return c.Log(ctx, SeverityNotice, payload)
+}
+
+// Warn calls Log with the severity set to Warning.
+func (c Client) Warn(ctx context.Context, payload interface{}) error {
+	return c.Log(ctx, SeverityWarning, payload)
+}
+
+// Error calls Log with the severity set to Error.
+func (c Client) Error(ctx context.Context, payload interface{}) error {
+	return c.Log(ctx, SeverityError, payload)
+}
+
+// Critical calls Log with the severity set to Critical.
+func (c Client) Critical(ctx context.Context, payload interface{}) error {
+	return c.Log(ctx, SeverityCritical, payload)
+}
+
+// Alert calls Log with the severity set to Alert.
+func (c Client) Alert(ctx context.Context, payload interface{}) error {
+	return c.Log(ctx, SeverityAlert, payload)
+}
+
+// Emergency calls Log with the severity set to Emergency.
+func (c Client) Emergency(ctx context.Context, payload interface{}) error {
+	return c.Log(ctx, SeverityEmergency, payload)
+}
+
+// AtSeverity returns a func that calls Log with the severity set to s,
+// for passing to code whose entries always have the same severity.
+func (c Client) AtSeverity(s Severity) func(ctx context.Context, payload interface{}) error {
+	return func(ctx context.Context, payload interface{}) error {
+		return c.Log(ctx, s, payload)
+	}
+}
+
+var (
+	singletonMu sync.Mutex
+	singleton   Client
+)
+
+// ErrMissingProject is passed to the handler set by SetErrorHandler when the package-level functions run
+// on Google Cloud without GCP_PROJECT or GOOGLE_CLOUD_PROJECT set, since every write would be rejected.
+var ErrMissingProject = errors.New("no project ID, set GCP_PROJECT or GOOGLE_CLOUD_PROJECT")
+
+// defaultClient returns the singleton client, creating it on first use.
+// Outside of Google Cloud, or when the logging client cannot be created, entries are written
+// to stderr as structured JSON instead.
+func defaultClient() (Client, error) {
+	singletonMu.Lock()
+	defer singletonMu.Unlock()
+
+	if singleton.writer == nil {
+		if !onGCP() {
+			singleton = localClient()
+			return singleton, nil
+		}
+		if firstEnv("GCP_PROJECT", "GOOGLE_CLOUD_PROJECT") == "" {
+			// The log name would have no project so warn once instead of failing every write.
+			handleError(fmt.Errorf("could not create logging client, writing logs to stderr: %w", ErrMissingProject))
+			singleton = localClient()
+			return singleton, nil
+		}
+
+		c, err := NewClient(context.Background())
+		if err != nil {
+			handleError(fmt.Errorf("could not create logging client, writing logs to stderr: %w", err))
+			c = localClient()
+		}
+		singleton = c
+	}
+	return singleton, nil
+}
+
+// SetDefaultClient makes the package-level functions use c instead of creating a client from the environment.
+// A previously set or created singleton is replaced without being closed.
+func SetDefaultClient(c Client) {
+	singletonMu.Lock()
+	defer singletonMu.Unlock()
+	singleton = c
+}
+
+// Close closes the singleton client and resets it so the next package-level call creates a new one.
+// It does nothing when no singleton has been created.
+func Close() error {
+	singletonMu.Lock()
+	c := singleton
+	singleton = Client{}
+	singletonMu.Unlock()
+
+	if c.writer == nil {
+		return nil
+	}
+	return c.Close()
+}
 
 // Log uses an auto generated singleton client.
 //
-//Warning: Any errors posting will be logged with no log severity.
+// Warning: Any errors posting are passed to the handler set by SetErrorHandler,
+// which by default logs them with no log severity. Use LogE to handle them instead.
+// If ctx is already cancelled or past its deadline the entry is not written
+// and the context error is handled the same way.
 func Log(ctx context.Context, severity Severity, payload interface{}) {
-	if singleton.client == nil {
-		var err error
-		singleton, err = NewClient(context.Background())
-		if err != nil {
-			log.Printf("Could not create client to log payload '%q': %v", payload, err)
-			return
-		}
+	if err := LogE(ctx, severity, payload); err != nil {
+		handleError(fmt.Errorf("could not log payload '%q': %w", payload, err))
 	}
+}
 
-	if err := singleton.Log(ctx, severity, payload); err != nil {
-		log.Printf("Could not log payload '%q': %v", payload, err)
+// errorHandler holds the func(error) errors that cannot be returned are passed to.
+var errorHandler atomic.Value
+
+// SetErrorHandler sets where errors that cannot be returned to the caller go, such as write errors of
+// asynchronous, batched, or package-level logging. A nil handler restores the default, which logs them
+// with the standard log package.
+func SetErrorHandler(h func(error)) {
+	if h == nil {
+		h = defaultErrorHandler
+	}
+	errorHandler.Store(h)
+}
+
+func defaultErrorHandler(err error) {
+	log.Print(err)
+}
+
+// handleError passes err to the handler set by SetErrorHandler.
+func handleError(err error) {
+	h, _ := errorHandler.Load().(func(error))
+	if h == nil {
+		h = defaultErrorHandler
 	}
+	h(err)
+}
+
+// minSeverity is the lowest severity the package-level functions write.
+var minSeverity atomic.Int32
+
+// SetMinSeverity makes the package-level functions skip entries below the severity given
+// without calling the logging API. It defaults to SeverityDefault so nothing is skipped.
+func SetMinSeverity(s Severity) {
+	minSeverity.Store(int32(s))
+}
+
+// disabled is whether every entry is skipped, set from the CFLOG_DISABLE environment variable
+// and changed by SetEnabled.
+var disabled atomic.Bool
+
+func init() {
+	off, _ := strconv.ParseBool(os.Getenv("CFLOG_DISABLE"))
+	disabled.Store(off)
+}
+
+// SetEnabled turns all logging by this package on or off at runtime, for every client and the
+// package-level functions. Disabled entries are skipped without calling the logging API and return nil.
+// Logging starts disabled when the CFLOG_DISABLE environment variable is true, such as "1",
+// which can cut log volume during an incident without a code change.
+func SetEnabled(enabled bool) {
+	disabled.Store(!enabled)
+}
+
+// LogE uses an auto generated singleton client like Log but returns any error.
+// Entries below the severity set by SetMinSeverity, or logged while disabled, are skipped and return nil.
+func LogE(ctx context.Context, severity Severity, payload interface{}) error {
+	return logDefault(severity, func(c Client) error {
+		return c.Log(ctx, severity, payload)
+	})
+}
+
+// logDefault calls log with the singleton client unless the severity is skipped.
+func logDefault(severity Severity, log func(Client) error) error {
+	if disabled.Load() || int32(severity) < minSeverity.Load() {
+		return nil
+	}
+
+	c, err := defaultClient()
+	if err != nil {
+		return fmt.Errorf("could not create client: %w", err)
+	}
+
+	return log(c)
 }
 
 // Debug calls Log with the severity set to Debug.
@@ -146,6 +772,9 @@ func Debug(ctx context.Context, payload interface{}) { Log(ctx, SeverityDebug, p
 // Info calls Log with the severity set to Info.
 func Info(ctx context.Context, payload interface{}) { Log(ctx, SeverityInfo, payload) }
 
+// Notice calls Log with the severity set to Notice.
+func Notice(ctx context.Context, payload interface{}) { Log(ctx, SeverityNotice, payload) }
+
 // Warn calls Log with the severity set to Warning.
 func Warn(ctx context.Context, payload interface{}) { Log(ctx, SeverityWarning, payload) }
 
@@ -154,3 +783,100 @@ func Error(ctx context.Context, payload interface{}) { Log(ctx, SeverityError, p
 
 // Critical calls Log with the severity set to Critical.
 func Critical(ctx context.Context, payload interface{}) { Log(ctx, SeverityCritical, payload) }
+
+// Alert calls Log with the severity set to Alert.
+func Alert(ctx context.Context, payload interface{}) { Log(ctx, SeverityAlert, payload) }
+
+// Emergency calls Log with the severity set to Emergency.
+func Emergency(ctx context.Context, payload interface{}) { Log(ctx, SeverityEmergency, payload) }
+
+// Debugf calls Log with the severity set to Debug and a payload formatted like fmt.Sprintf.
+func Debugf(ctx context.Context, format string, args ...interface{}) {
+	Log(ctx, SeverityDebug, fmt.Sprintf(format, args...))
+}
+
+// Infof calls Log with the severity set to Info and a payload formatted like fmt.Sprintf.
+func Infof(ctx context.Context, format string, args ...interface{}) {
+	Log(ctx, SeverityInfo, fmt.Sprintf(format, args...))
+}
+
+// Noticef calls Log with the severity set to Notice and a payload formatted like fmt.Sprintf.
+func Noticef(ctx context.Context, format string, args ...interface{}) {
+	Log(ctx, SeverityNotice, fmt.Sprintf(format, args...))
+}
+
+// Warnf calls Log with the severity set to Warning and a payload formatted like fmt.Sprintf.
+func Warnf(ctx context.Context, format string, args ...interface{}) {
+	Log(ctx, SeverityWarning, fmt.Sprintf(format, args...))
+}
+
+// Errorf calls Log with the severity set to Error and a payload formatted like fmt.Sprintf.
+func Errorf(ctx context.Context, format string, args ...interface{}) {
+	Log(ctx, SeverityError, fmt.Sprintf(format, args...))
+}
+
+// Criticalf calls Log with the severity set to Critical and a payload formatted like fmt.Sprintf.
+func Criticalf(ctx context.Context, format string, args ...interface{}) {
+	Log(ctx, SeverityCritical, fmt.Sprintf(format, args...))
+}
+
+// Alertf calls Log with the severity set to Alert and a payload formatted like fmt.Sprintf.
+func Alertf(ctx context.Context, format string, args ...interface{}) {
+	Log(ctx, SeverityAlert, fmt.Sprintf(format, args...))
+}
+
+// Emergencyf calls Log with the severity set to Emergency and a payload formatted like fmt.Sprintf.
+func Emergencyf(ctx context.Context, format string, args ...interface{}) {
+	Log(ctx, SeverityEmergency, fmt.Sprintf(format, args...))
+}
+
+// DebugKV calls Log with the severity set to Debug like Client.LogKV.
+func DebugKV(ctx context.Context, message string, kvs ...interface{}) {
+	logKV(ctx, SeverityDebug, message, kvs)
+}
+
+// InfoKV calls Log with the severity set to Info like Client.LogKV.
+func InfoKV(ctx context.Context, message string, kvs ...interface{}) {
+	logKV(ctx, SeverityInfo, message, kvs)
+}
+
+// NoticeKV calls Log with the severity set to Notice like Client.LogKV.
+func NoticeKV(ctx context.Context, message string, kvs ...interface{}) {
+	logKV(ctx, SeverityNotice, message, kvs)
+}
+
+// WarnKV calls Log with the severity set to Warning like Client.LogKV.
+func WarnKV(ctx context.Context, message string, kvs ...interface{}) {
+	logKV(ctx, SeverityWarning, message, kvs)
+}
+
+// ErrorKV calls Log with the severity set to Error like Client.LogKV.
+func ErrorKV(ctx context.Context, message string, kvs ...interface{}) {
+	logKV(ctx, SeverityError, message, kvs)
+}
+
+// CriticalKV calls Log with the severity set to Critical like Client.LogKV.
+func CriticalKV(ctx context.Context, message string, kvs ...interface{}) {
+	logKV(ctx, SeverityCritical, message, kvs)
+}
+
+// AlertKV calls Log with the severity set to Alert like Client.LogKV.
+func AlertKV(ctx context.Context, message string, kvs ...interface{}) {
+	logKV(ctx, SeverityAlert, message, kvs)
+}
+
+// EmergencyKV calls Log with the severity set to Emergency like Client.LogKV.
+func EmergencyKV(ctx context.Context, message string, kvs ...interface{}) {
+	logKV(ctx, SeverityEmergency, message, kvs)
+}
+
+// logKV calls LogKV on the singleton client for the package-level key value functions
+// so the message is under the client's message key.
+func logKV(ctx context.Context, severity Severity, message string, kvs []interface{}) {
+	err := logDefault(severity, func(c Client) error {
+		return c.LogKV(ctx, severity, message, kvs...)
+	})
+	if err != nil {
+		handleError(fmt.Errorf("could not log %q: %w", message, err))
+	}
+}
@@ -1,44 +1,739 @@
 package cflog
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	logging "cloud.google.com/go/logging/apiv2"
+	"google.golang.org/api/option"
 	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+	"google.golang.org/grpc"
 )
 
-func TestSetEntrypayload(t *testing.T) {
-	textType := "*logging.LogEntry_TextPayload"
-	jsonType := "*logging.LogEntry_JsonPayload"
+func TestLogName(t *testing.T) {
+	tests := []struct {
+		name     string
+		logID    string
+		expected string
+	}{
+		{name: "default", logID: defaultLogID, expected: "projects/p/logs/cloudfunctions.googleapis.com%2Fcloud-functions"},
+		{name: "custom", logID: "my-service", expected: "projects/p/logs/my-service"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if n := logName("p", test.logID); n != test.expected {
+				t.Fatal("Unexpected log name", n)
+			}
+		})
+	}
+}
+
+func TestFirstEnv(t *testing.T) {
+	t.Setenv("CFLOG_TEST_A", "")
+	t.Setenv("CFLOG_TEST_B", "b")
+	t.Setenv("CFLOG_TEST_C", "c")
+
+	if v := firstEnv("CFLOG_TEST_A", "CFLOG_TEST_B", "CFLOG_TEST_C"); v != "b" {
+		t.Fatal("Unexpected value", v)
+	}
+	if v := firstEnv("CFLOG_TEST_A"); v != "" {
+		t.Fatal("Unexpected value", v)
+	}
+}
+
+func TestNewClientOptions(t *testing.T) {
+	t.Setenv("GCP_PROJECT", "env-project")
+
+	c := newClient()
+	if c.logName != "projects/env-project/logs/cloudfunctions.googleapis.com%2Fcloud-functions" {
+		t.Fatal("Unexpected default log name", c.logName)
+	}
+
+	c = newClient(WithProjectID("first"), WithProjectID("second"), WithLogName("custom"))
+	if c.logName != "projects/second/logs/custom" {
+		t.Fatal("Unexpected log name", c.logName)
+	}
+	if p := c.logMonitoredResource.Labels["project_id"]; p != "second" {
+		t.Fatal("Unexpected resource project", p)
+	}
+}
+
+func TestDefaultLabels(t *testing.T) {
+	c := newClient(
+		WithDefaultLabels(map[string]string{"a": "1", "b": "1"}),
+		WithDefaultLabels(map[string]string{"b": "2"}),
+	)
+	entry, err := c.BuildEntry(context.Background(), SeverityInfo, "message")
+	if err != nil {
+		t.Fatal("Entry error", err)
+	}
+	if entry.Labels["a"] != "1" || entry.Labels["b"] != "2" {
+		t.Fatal("Unexpected labels", entry.Labels)
+	}
+
+	merged := mergeLabels(entry.Labels, map[string]string{"a": "override"})
+	if merged["a"] != "override" || entry.Labels["a"] != "1" {
+		t.Fatal("Unexpected merge", merged, entry.Labels)
+	}
+
+	if l := mergeLabels(nil, map[string]string{}); l != nil {
+		t.Fatal("Expected nil labels", l)
+	}
+}
+
+func TestInsertIDGenerator(t *testing.T) {
+	entry, err := newClient().BuildEntry(context.Background(), SeverityInfo, "message")
+	if err != nil {
+		t.Fatal("Entry error", err)
+	}
+	if entry.InsertId != "" {
+		t.Fatal("Expected empty insertId", entry.InsertId)
+	}
+
+	entry, err = newClient(WithInsertIDGenerator(func() string { return "id" })).BuildEntry(context.Background(), SeverityInfo, "message")
+	if err != nil {
+		t.Fatal("Entry error", err)
+	}
+	if entry.InsertId != "id" {
+		t.Fatal("Unexpected insertId", entry.InsertId)
+	}
+}
+
+func TestNopClient(t *testing.T) {
+	c := NewNopClient()
+	if err := c.Error(context.Background(), "message"); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal("Close error", err)
+	}
+}
+
+func TestLogfAndLogFields(t *testing.T) {
+	ctx := context.Background()
+	c, w := newFakeClient()
+
+	if err := c.Logf(ctx, SeverityInfo, "processed %d items", 3); err != nil {
+		t.Fatal("Log error", err)
+	}
+	fields := map[string]interface{}{"items": 3}
+	if err := c.LogFields(ctx, SeverityInfo, "processed", fields); err != nil {
+		t.Fatal("Log error", err)
+	}
+
+	entries := w.entries()
+	if p := entries[0].GetTextPayload(); p != "processed 3 items" {
+		t.Fatal("Unexpected text", p)
+	}
+	payload := entries[1].GetJsonPayload().GetFields()
+	if payload["message"].GetStringValue() != "processed" || payload["items"].GetNumberValue() != 3 {
+		t.Fatal("Unexpected payload", payload)
+	}
+	if _, ok := fields["message"]; ok {
+		t.Fatal("Expected fields to be left unchanged", fields)
+	}
+}
+
+func TestSetMinSeverity(t *testing.T) {
+	defer SetMinSeverity(SeverityDefault)
+	SetMinSeverity(SeverityWarning)
+
+	// The singleton is never created for skipped entries.
+	if err := LogE(context.Background(), SeverityInfo, "skipped"); err != nil {
+		t.Fatal("Log error", err)
+	}
+	singletonMu.Lock()
+	defer singletonMu.Unlock()
+	if singleton.writer != nil {
+		t.Fatal("Expected no singleton to be created")
+	}
+}
+
+func TestWithMinSeverity(t *testing.T) {
+	ctx := context.Background()
+	c, w := newFakeClient(WithMinSeverity(SeverityWarning))
+
+	if err := c.Info(ctx, "skipped"); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if err := c.LogWithLabels(ctx, SeverityDebug, "skipped", nil); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if len(w.requests) != 0 {
+		t.Fatal("Expected no requests for filtered severities", w.requests)
+	}
+
+	if err := c.Warn(ctx, "written"); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if len(w.requests) != 1 {
+		t.Fatal("Expected a request", w.requests)
+	}
+}
+
+func TestWithSampleRate(t *testing.T) {
+	ctx := context.Background()
+	c, w := newFakeClient(WithSampleRate(SeverityError, 0))
+
+	if err := c.Debug(ctx, "dropped"); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if len(w.requests) != 0 {
+		t.Fatal("Expected sampled entry to be dropped", w.requests)
+	}
+
+	if err := c.Error(ctx, "kept"); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if len(w.requests) != 1 {
+		t.Fatal("Expected errors to always be kept", w.requests)
+	}
+}
+
+func TestLogCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c, w := newFakeClient()
+	if err := c.Info(ctx, "message"); err != context.Canceled {
+		t.Fatal("Expected context error", err)
+	}
+	if len(w.requests) != 0 {
+		t.Fatal("Expected no request", w.requests)
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	c, _ := newFakeClient(WithAsync(1))
+	if err := c.Close(); err != nil {
+		t.Fatal("Close error", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal("Second close error", err)
+	}
+
+	if err := (Client{}).Close(); err != nil {
+		t.Fatal("Zero value close error", err)
+	}
+}
+
+func TestFlushAndClose(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "batch", opts: []Option{WithBatchSize(10)}},
+		{name: "async", opts: []Option{WithAsync(10), WithBlockWhenFull(true)}},
+		{name: "batch and async", opts: []Option{WithBatchSize(10), WithAsync(10), WithBlockWhenFull(true)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, flush := range []bool{true, false} {
+				c, w := newFakeClient(test.opts...)
+				for i := 0; i < 3; i++ {
+					if err := c.Info(ctx, "message"); err != nil {
+						t.Fatal("Log error", err)
+					}
+				}
+				if c.batch != nil && len(w.entries()) != 0 {
+					t.Fatal("Expected batched entries to be pending", len(w.entries()))
+				}
+
+				if flush {
+					if err := c.Flush(); err != nil {
+						t.Fatal("Flush error", err)
+					}
+					if n := len(w.entries()); n != 3 {
+						t.Fatal("Expected flush to write pending entries", n)
+					}
+				}
+				if err := c.Close(); err != nil {
+					t.Fatal("Close error", err)
+				}
+				if n := len(w.entries()); n != 3 {
+					t.Fatal("Expected close to write pending entries once", flush, n)
+				}
+			}
+		})
+	}
+}
+
+func TestPackageClose(t *testing.T) {
+	if err := Close(); err != nil {
+		t.Fatal("Close with no singleton error", err)
+	}
+
+	singletonMu.Lock()
+	singleton, _ = newFakeClient()
+	singletonMu.Unlock()
+	if err := Close(); err != nil {
+		t.Fatal("Close error", err)
+	}
+
+	singletonMu.Lock()
+	defer singletonMu.Unlock()
+	if singleton.writer != nil {
+		t.Fatal("Expected the singleton to be reset")
+	}
+}
+
+func TestSetDefaultClient(t *testing.T) {
+	c, w := newFakeClient(WithDefaultLabels(map[string]string{"a": "b"}))
+	SetDefaultClient(c)
+	defer Close()
+
+	Info(context.Background(), "hello")
+	entries := w.entries()
+	if len(entries) != 1 {
+		t.Fatal("Unexpected entries", entries)
+	}
+	if entries[0].Labels["a"] != "b" {
+		t.Fatal("Unexpected labels", entries[0].Labels)
+	}
+}
+
+func TestWithEndpoint(t *testing.T) {
+	c := newClient(WithEndpoint("us-central1-logging.googleapis.com:443"))
+	if len(c.clientOptions) != 1 {
+		t.Fatal("Unexpected client options", c.clientOptions)
+	}
+}
+
+// fakeLoggingServer records the entries written to it and fails every other call.
+type fakeLoggingServer struct {
+	loggingpb.LoggingServiceV2Server
+	mu      sync.Mutex
+	entries []*loggingpb.LogEntry
+}
+
+func (s *fakeLoggingServer) WriteLogEntries(ctx context.Context, req *loggingpb.WriteLogEntriesRequest) (*loggingpb.WriteLogEntriesResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, req.Entries...)
+	return &loggingpb.WriteLogEntriesResponse{}, nil
+}
+
+func TestWithClientOptions(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen error", err)
+	}
+	fake := &fakeLoggingServer{}
+	srv := grpc.NewServer()
+	loggingpb.RegisterLoggingServiceV2Server(srv, fake)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	ctx := context.Background()
+	c, err := NewClient(ctx,
+		WithProjectID("p"),
+		WithEndpoint(lis.Addr().String()),
+		WithClientOptions(option.WithoutAuthentication(), option.WithGRPCDialOption(grpc.WithInsecure())),
+	)
+	if err != nil {
+		t.Fatal("NewClient error", err)
+	}
+	defer c.Close()
+	if c.Underlying() == nil {
+		t.Fatal("Missing underlying client")
+	}
+
+	if err := c.Info(ctx, "hello"); err != nil {
+		t.Fatal("Log error", err)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.entries) != 1 || fake.entries[0].GetTextPayload() != "hello" {
+		t.Fatal("Unexpected entries", fake.entries)
+	}
+}
+
+func TestNewClientWithLogging(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen error", err)
+	}
+	fake := &fakeLoggingServer{}
+	srv := grpc.NewServer()
+	loggingpb.RegisterLoggingServiceV2Server(srv, fake)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	ctx := context.Background()
+	client, err := logging.NewClient(ctx,
+		option.WithEndpoint(lis.Addr().String()),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithInsecure()),
+	)
+	if err != nil {
+		t.Fatal("Logging client error", err)
+	}
+	c := NewClientWithLogging(client, WithProjectID("p"), WithLogName("my-service"), WithDefaultLabels(map[string]string{"a": "b"}))
+	if c.Underlying() != client {
+		t.Fatal("Unexpected underlying client", c.Underlying())
+	}
+
+	if err := c.Info(ctx, "hello"); err != nil {
+		t.Fatal("Log error", err)
+	}
+	fake.mu.Lock()
+	entries := fake.entries
+	fake.mu.Unlock()
+	if len(entries) != 1 || entries[0].GetTextPayload() != "hello" {
+		t.Fatal("Unexpected entries", entries)
+	}
+	if entries[0].LogName != "projects/p/logs/my-service" || entries[0].Labels["a"] != "b" {
+		t.Fatal("Unexpected entry", entries[0])
+	}
 
+	if err := c.Close(); err != nil {
+		t.Fatal("Close error", err)
+	}
+	if _, err := client.WriteLogEntries(ctx, &loggingpb.WriteLogEntriesRequest{}); err == nil {
+		t.Fatal("Expected the logging client to be closed")
+	}
+}
+
+func TestUnderlyingWithWriter(t *testing.T) {
+	c, _ := newFakeClient()
+	if c.Underlying() != nil {
+		t.Fatal("Unexpected underlying client", c.Underlying())
+	}
+}
+
+func TestLogTo(t *testing.T) {
+	c, w := newFakeClient(WithProjectID("p"))
+	if err := c.LogTo(context.Background(), "billing/jobs", SeverityInfo, "done"); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if name := w.entries()[0].LogName; name != "projects/p/logs/billing%2Fjobs" {
+		t.Fatal("Unexpected log name", name)
+	}
+}
+
+func TestSetErrorHandler(t *testing.T) {
+	var handled []error
+	SetErrorHandler(func(err error) { handled = append(handled, err) })
+	defer SetErrorHandler(nil)
+
+	SetDefaultClient(NewNopClient())
+	defer Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	Info(ctx, "cancelled")
+	if len(handled) != 1 || !errors.Is(handled[0], context.Canceled) {
+		t.Fatal("Unexpected handled errors", handled)
+	}
+}
+
+func TestDefaultClientMissingProject(t *testing.T) {
+	t.Setenv("K_SERVICE", "svc")
+	t.Setenv("GCP_PROJECT", "")
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+	var handled []error
+	SetErrorHandler(func(err error) { handled = append(handled, err) })
+	defer SetErrorHandler(nil)
+	defer Close()
+
+	for i := 0; i < 2; i++ {
+		c, err := defaultClient()
+		if err != nil {
+			t.Fatal("Client error", err)
+		}
+		if _, ok := c.writer.(*jsonLineWriter); !ok {
+			t.Fatal("Unexpected writer", c.writer)
+		}
+	}
+	if len(handled) != 1 || !errors.Is(handled[0], ErrMissingProject) {
+		t.Fatal("Unexpected handled errors", handled)
+	}
+}
+
+func TestDefaultClientConcurrent(t *testing.T) {
+	for _, key := range []string{"GCP_PROJECT", "GOOGLE_CLOUD_PROJECT", "FUNCTION_NAME", "K_SERVICE"} {
+		t.Setenv(key, "")
+	}
+	Close()
+	defer Close()
+
+	var created int32
+	var w *fakeWriter
+	original := localClient
+	localClient = func() Client {
+		atomic.AddInt32(&created, 1)
+		var c Client
+		c, w = newFakeClient()
+		return c
+	}
+	defer func() { localClient = original }()
+
+	const goroutines = 50
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if i%2 == 0 {
+				Log(context.Background(), SeverityInfo, "log")
+				return
+			}
+			if err := LogE(context.Background(), SeverityInfo, "log"); err != nil {
+				t.Error("Log error", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if created != 1 {
+		t.Fatal("Unexpected clients created", created)
+	}
+	if n := len(w.entries()); n != goroutines {
+		t.Fatal("Unexpected entry count", n)
+	}
+}
+
+func TestLogOperation(t *testing.T) {
+	c, w := newFakeClient()
+	ctx := context.Background()
+	op := &loggingpb.LogEntryOperation{Id: "job-1", Producer: "github.com/mvndaai/cflog", First: true}
+	if err := c.LogOperation(ctx, SeverityInfo, "started", op); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if err := c.LogOperation(ctx, SeverityInfo, "no operation", nil); err != nil {
+		t.Fatal("Log error", err)
+	}
+
+	entries := w.entries()
+	if got := entries[0].Operation; got.GetId() != "job-1" || got.GetProducer() != "github.com/mvndaai/cflog" || !got.GetFirst() {
+		t.Fatal("Unexpected operation", got)
+	}
+	if entries[1].Operation != nil {
+		t.Fatal("Expected no operation", entries[1].Operation)
+	}
+}
+
+func TestWith(t *testing.T) {
+	c, w := newFakeClient(WithProjectID("p"), WithDefaultLabels(map[string]string{"a": "1", "b": "1"}))
+	child := c.With(map[string]string{"b": "2"}, "abc123/1;o=1")
+
+	ctx := context.Background()
+	child.Info(ctx, "child")
+	c.Info(ctx, "parent")
+
+	entries := w.entries()
+	if l := entries[0].Labels; l["a"] != "1" || l["b"] != "2" {
+		t.Fatal("Unexpected child labels", l)
+	}
+	if entries[0].Trace != "projects/p/traces/abc123" || !entries[0].TraceSampled {
+		t.Fatal("Unexpected child trace", entries[0].Trace)
+	}
+	if l := entries[1].Labels; l["b"] != "1" || entries[1].Trace != "" {
+		t.Fatal("Expected the parent to be unchanged", l, entries[1].Trace)
+	}
+}
+
+func TestSetEnabled(t *testing.T) {
+	c, w := newFakeClient()
+	SetEnabled(false)
+	defer SetEnabled(true)
+
+	if err := c.Info(context.Background(), "skipped"); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if err := LogE(context.Background(), SeverityError, "skipped"); err != nil {
+		t.Fatal("LogE error", err)
+	}
+	if entries := w.entries(); len(entries) != 0 {
+		t.Fatal("Expected no entries", entries)
+	}
+
+	SetEnabled(true)
+	if err := c.Info(context.Background(), "written"); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if entries := w.entries(); len(entries) != 1 {
+		t.Fatal("Unexpected entries", entries)
+	}
+}
+
+func TestLogKV(t *testing.T) {
+	tests := []struct {
+		name     string
+		kvs      []interface{}
+		expected string
+	}{
+		{name: "pairs", kvs: []interface{}{"user_id", 7, "plan", "pro"}, expected: `{"message":"user created","plan":"pro","user_id":7}`},
+		{name: "odd", kvs: []interface{}{"user_id", 7, "dangling"}, expected: `{"!BADKEY":"dangling","message":"user created","user_id":7}`},
+		{name: "non-string key", kvs: []interface{}{1, "plan", "pro"}, expected: `{"!BADKEY":1,"message":"user created","plan":"pro"}`},
+		{name: "none", expected: `{"message":"user created"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, w := newFakeClient()
+			if err := c.LogKV(context.Background(), SeverityInfo, "user created", test.kvs...); err != nil {
+				t.Fatal("Log error", err)
+			}
+			if got := payloadJSON(t, w.entries()[0].GetJsonPayload()); got != test.expected {
+				t.Fatal("Unexpected payload", got)
+			}
+		})
+	}
+
+	c, w := newFakeClient()
+	SetDefaultClient(c)
+	defer Close()
+	WarnKV(context.Background(), "slow", "ms", 1200)
+	if e := w.entries()[0]; Severity(e.Severity) != SeverityWarning || payloadJSON(t, e.GetJsonPayload()) != `{"message":"slow","ms":1200}` {
+		t.Fatal("Unexpected entry", e)
+	}
+}
+
+func TestLogAt(t *testing.T) {
+	c, w := newFakeClient()
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	if err := c.LogAt(context.Background(), SeverityInfo, "replayed", ts); err != nil {
+		t.Fatal("Log error", err)
+	}
+	entries := w.entries()
+	if len(entries) != 1 || !entries[0].Timestamp.AsTime().Equal(ts) {
+		t.Fatal("Unexpected entries", entries)
+	}
+
+	if err := c.LogAt(context.Background(), SeverityInfo, "invalid", time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Fatal("Expected an invalid timestamp error")
+	}
+	if n := len(w.entries()); n != 1 {
+		t.Fatal("Unexpected entries after invalid timestamp", n)
+	}
+}
+
+func TestLogTimed(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	thresholds := map[time.Duration]Severity{time.Second: SeverityWarning, 5 * time.Second: SeverityError}
 	tests := []struct {
-		name         string
-		input        interface{}
-		expectedType string
+		name     string
+		elapsed  time.Duration
+		payload  interface{}
+		severity Severity
+		expected string
 	}{
-		{name: "string", input: "str", expectedType: textType},
-		{name: "empty string", input: "", expectedType: textType},
-		{name: "[]byte", input: []byte("bytes"), expectedType: textType},
-		{name: "nil", input: nil, expectedType: textType},
-		{name: "struct", input: struct{ M string }{M: "in"}, expectedType: jsonType},
-		{name: "JSON string", input: `{"m": "m"}`, expectedType: jsonType},
-		{name: "bad JSON string", input: `{m": "m"}`, expectedType: textType},
-		{name: "empty struct", input: struct{}{}, expectedType: jsonType},
-		{name: "uninitialized struct", input: struct{ M string }{}, expectedType: jsonType},
+		{name: "fast", elapsed: 10 * time.Millisecond, payload: "done", severity: SeverityInfo, expected: `{"duration":"10ms","message":"done"}`},
+		{name: "at threshold", elapsed: time.Second, payload: "done", severity: SeverityWarning, expected: `{"duration":"1s","message":"done"}`},
+		{name: "slow", elapsed: 1500 * time.Millisecond, payload: map[string]interface{}{"op": "sync"}, severity: SeverityWarning, expected: `{"duration":"1.5s","op":"sync"}`},
+		{name: "slowest", elapsed: time.Minute, payload: "done", severity: SeverityError, expected: `{"duration":"1m0s","message":"done"}`},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			entry := &loggingpb.LogEntry{}
-			if err := setEntryPayload(entry, test.input); err != nil {
-				t.Fatal("Set error", err)
+			c, w := newFakeClient(WithClock(func() time.Time { return start.Add(test.elapsed) }))
+			if err := c.LogTimed(context.Background(), start, thresholds, test.payload); err != nil {
+				t.Fatal("Log error", err)
 			}
+			e := w.entries()[0]
+			if Severity(e.Severity) != test.severity {
+				t.Fatal("Unexpected severity", e.Severity)
+			}
+			if got := payloadJSON(t, e.GetJsonPayload()); got != test.expected {
+				t.Fatal("Unexpected payload", got)
+			}
+		})
+	}
+}
+
+func TestWithRequestHook(t *testing.T) {
+	var requests int
+	c, w := newFakeClient(WithBatchSize(2), WithRequestHook(func(req *loggingpb.WriteLogEntriesRequest) {
+		requests++
+		for _, e := range req.Entries {
+			e.Labels = mergeLabels(e.Labels, map[string]string{"stamp": "s"})
+		}
+	}))
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if err := c.Info(ctx, "message"); err != nil {
+			t.Fatal("Log error", err)
+		}
+	}
+
+	entries := w.entries()
+	if requests != 1 || len(entries) != 2 {
+		t.Fatal("Unexpected requests", requests, entries)
+	}
+	for _, e := range entries {
+		if e.Labels["stamp"] != "s" {
+			t.Fatal("Unexpected labels", e.Labels)
+		}
+	}
+}
 
-			pType := fmt.Sprintf("%T", entry.Payload)
-			if pType != test.expectedType {
-				t.Fatal("Unexpected type", pType)
+func TestAtSeverity(t *testing.T) {
+	c, w := newFakeClient(WithMinSeverity(SeverityInfo))
+	audit := c.AtSeverity(SeverityNotice)
+	debug := c.AtSeverity(SeverityDebug)
+
+	if err := audit(context.Background(), "signed in"); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if err := debug(context.Background(), "skipped"); err != nil {
+		t.Fatal("Log error", err)
+	}
+	entries := w.entries()
+	if len(entries) != 1 || Severity(entries[0].Severity) != SeverityNotice || entries[0].GetTextPayload() != "signed in" {
+		t.Fatal("Unexpected entries", entries)
+	}
+}
+
+func TestWithMessageKey(t *testing.T) {
+	ctx := WithFields(context.Background(), map[string]interface{}{"request_id": "r1"})
+	tests := []struct {
+		name     string
+		log      func(c Client) error
+		expected string
+	}{
+		{name: "fields", log: func(c Client) error {
+			return c.LogFields(ctx, SeverityInfo, "hi", map[string]interface{}{"a": 1})
+		}, expected: `{"a":1,"msg":"hi","request_id":"r1"}`},
+		{name: "kv", log: func(c Client) error {
+			return c.LogKV(ctx, SeverityInfo, "hi", "a", 1)
+		}, expected: `{"a":1,"msg":"hi","request_id":"r1"}`},
+		{name: "text with context fields", log: func(c Client) error {
+			return c.Log(ctx, SeverityInfo, "hi")
+		}, expected: `{"msg":"hi","request_id":"r1"}`},
+		{name: "error payload", log: func(c Client) error {
+			return c.Log(ctx, SeverityError, errors.New("boom"))
+		}, expected: `{"msg":"boom","request_id":"r1"}`},
+		{name: "log error", log: func(c Client) error {
+			return c.LogError(ctx, errors.New("boom"))
+		}, expected: `{"msg":"boom","request_id":"r1"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, w := newFakeClient(WithMessageKey("msg"))
+			if err := test.log(c); err != nil {
+				t.Fatal("Log error", err)
+			}
+			if got := payloadJSON(t, w.entries()[0].GetJsonPayload()); got != test.expected {
+				t.Fatal("Unexpected payload", got)
 			}
 		})
 	}
 
+	c, w := newFakeClient(WithMessageKey("msg"))
+	SetDefaultClient(c)
+	defer Close()
+	InfoKV(context.Background(), "hi", "a", 1)
+	if got := payloadJSON(t, w.entries()[0].GetJsonPayload()); got != `{"a":1,"msg":"hi"}` {
+		t.Fatal("Unexpected payload", got)
+	}
 }
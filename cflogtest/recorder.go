@@ -0,0 +1,77 @@
+// Package cflogtest helps test code that logs with cflog.
+package cflogtest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mvndaai/cflog"
+)
+
+// Recorder is a cflog.Logger that keeps every entry logged with it in memory instead of writing it,
+// so tests can check what code logged without Google Cloud. It is safe for concurrent use.
+type Recorder struct {
+	mu      sync.Mutex
+	entries []cflog.Entry
+}
+
+var _ cflog.Logger = (*Recorder)(nil)
+
+// NewRecorder creates an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Log implements cflog.Logger by recording the entry. It returns the context error when ctx is done,
+// like cflog.Client.Log, and nil otherwise.
+func (r *Recorder) Log(ctx context.Context, severity cflog.Severity, payload interface{}) error {
+	return r.LogWithLabels(ctx, severity, payload, nil)
+}
+
+// LogWithLabels records the entry with its labels like cflog.Client.LogWithLabels.
+func (r *Recorder) LogWithLabels(ctx context.Context, severity cflog.Severity, payload interface{}, labels map[string]string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, cflog.Entry{Severity: severity, Payload: payload, Labels: labels})
+	return nil
+}
+
+// Entries returns every entry recorded, oldest first.
+func (r *Recorder) Entries() []cflog.Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]cflog.Entry(nil), r.entries...)
+}
+
+// LastEntry returns the most recent entry, and false if nothing has been logged.
+func (r *Recorder) LastEntry() (cflog.Entry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entries) == 0 {
+		return cflog.Entry{}, false
+	}
+	return r.entries[len(r.entries)-1], true
+}
+
+// EntriesWithSeverity returns the entries recorded with the severity given, oldest first.
+func (r *Recorder) EntriesWithSeverity(s cflog.Severity) []cflog.Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matched []cflog.Entry
+	for _, e := range r.entries {
+		if e.Severity == s {
+			matched = append(matched, e)
+		}
+	}
+	return matched
+}
+
+// Reset forgets every entry recorded so far.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = nil
+}
@@ -0,0 +1,61 @@
+package cflogtest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mvndaai/cflog"
+)
+
+// chargeCard stands in for code under test that takes a cflog.Logger.
+func chargeCard(ctx context.Context, l cflog.Logger, amount int) {
+	if amount <= 0 {
+		l.Log(ctx, cflog.SeverityError, map[string]interface{}{"message": "invalid amount", "amount": amount})
+		return
+	}
+	l.Log(ctx, cflog.SeverityInfo, "charged")
+}
+
+func TestRecorder(t *testing.T) {
+	ctx := context.Background()
+	r := NewRecorder()
+	if _, ok := r.LastEntry(); ok {
+		t.Fatal("Expected no entries")
+	}
+
+	chargeCard(ctx, r, 10)
+	chargeCard(ctx, r, -1)
+	r.LogWithLabels(ctx, cflog.SeverityInfo, "labeled", map[string]string{"k": "v"})
+
+	if n := len(r.Entries()); n != 3 {
+		t.Fatal("Unexpected entry count", n)
+	}
+	errs := r.EntriesWithSeverity(cflog.SeverityError)
+	if len(errs) != 1 {
+		t.Fatal("Unexpected errors", errs)
+	}
+	if fields, _ := errs[0].Payload.(map[string]interface{}); fields["amount"] != -1 {
+		t.Fatal("Unexpected payload", errs[0].Payload)
+	}
+	last, ok := r.LastEntry()
+	if !ok || last.Payload != "labeled" || last.Labels["k"] != "v" {
+		t.Fatal("Unexpected last entry", last)
+	}
+
+	r.Reset()
+	if n := len(r.Entries()); n != 0 {
+		t.Fatal("Expected no entries after Reset", n)
+	}
+}
+
+func TestRecorderCancelledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := NewRecorder()
+	if err := r.Log(ctx, cflog.SeverityInfo, "skipped"); err != context.Canceled {
+		t.Fatal("Unexpected error", err)
+	}
+	if n := len(r.Entries()); n != 0 {
+		t.Fatal("Expected no entries", n)
+	}
+}
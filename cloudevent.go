@@ -0,0 +1,31 @@
+package cflog
+
+import "context"
+
+// CloudEvent is the part of a CloudEvent used to correlate entries with the event that triggered them.
+// The Event type of github.com/cloudevents/sdk-go satisfies it, so it can be passed without this
+// package depending on the SDK.
+type CloudEvent interface {
+	ID() string
+	Type() string
+	Source() string
+	Subject() string
+}
+
+// cloudEventKey is the JSON payload field holding the event attributes added by WithCloudEvent.
+const cloudEventKey = "cloud_event"
+
+// WithCloudEvent returns a copy of ctx carrying the ID, type, source, and subject of the event as
+// fields, as WithFields does, so every entry logged while handling it can be found by the event.
+// They are under "cloud_event" in the JSON payload, with an empty subject left out.
+func WithCloudEvent(ctx context.Context, e CloudEvent) context.Context {
+	attrs := map[string]interface{}{
+		"id":     e.ID(),
+		"type":   e.Type(),
+		"source": e.Source(),
+	}
+	if s := e.Subject(); s != "" {
+		attrs["subject"] = s
+	}
+	return WithFields(ctx, map[string]interface{}{cloudEventKey: attrs})
+}
@@ -0,0 +1,28 @@
+package cflog
+
+import (
+	"context"
+	"testing"
+)
+
+type testEvent struct{ id, typ, source, subject string }
+
+func (e testEvent) ID() string      { return e.id }
+func (e testEvent) Type() string    { return e.typ }
+func (e testEvent) Source() string  { return e.source }
+func (e testEvent) Subject() string { return e.subject }
+
+func TestWithCloudEvent(t *testing.T) {
+	c, _ := newFakeClient()
+	e := testEvent{id: "1", typ: "google.cloud.pubsub.topic.v1.messagePublished", source: "//pubsub.googleapis.com/projects/p/topics/t"}
+	ctx := WithCloudEvent(context.Background(), e)
+
+	entry, err := c.BuildEntry(ctx, SeverityInfo, "handled")
+	if err != nil {
+		t.Fatal("Build error", err)
+	}
+	expected := `{"cloud_event":{"id":"1","source":"//pubsub.googleapis.com/projects/p/topics/t","type":"google.cloud.pubsub.topic.v1.messagePublished"},"message":"handled"}`
+	if got := payloadJSON(t, entry.GetJsonPayload()); got != expected {
+		t.Fatal("Unexpected payload", got)
+	}
+}
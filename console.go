@@ -0,0 +1,111 @@
+package cflog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	gax "github.com/googleapis/gax-go/v2"
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+)
+
+// consoleWriter is an EntryWriter that writes each entry as a line of text for people to read,
+// colored by severity when writing to a terminal.
+type consoleWriter struct {
+	mu    sync.Mutex
+	w     io.Writer
+	color bool
+	// now stamps entries without a timestamp and messageKey is the field shown as the message.
+	// newClient sets them from the client's WithClock and WithMessageKey.
+	now        func() time.Time
+	messageKey string
+}
+
+// newConsoleWriter creates a consoleWriter that colors its output when w is a terminal
+// and the NO_COLOR environment variable is not set.
+// https://no-color.org
+func newConsoleWriter(w io.Writer) *consoleWriter {
+	_, noColor := os.LookupEnv("NO_COLOR")
+	return &consoleWriter{w: w, color: !noColor && isTerminal(w), now: time.Now, messageKey: defaultMessageKey}
+}
+
+// isTerminal reports whether w is a character device such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (w *consoleWriter) WriteLogEntries(ctx context.Context, req *loggingpb.WriteLogEntriesRequest, opts ...gax.CallOption) (*loggingpb.WriteLogEntriesResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, entry := range req.Entries {
+		line, err := w.format(entry)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(w.w, line); err != nil {
+			return nil, err
+		}
+	}
+	return &loggingpb.WriteLogEntriesResponse{}, nil
+}
+
+// format renders an entry as its time, severity, and message, followed by any other JSON payload fields.
+// Entries without a timestamp use the time they are written.
+func (w *consoleWriter) format(entry *loggingpb.LogEntry) (string, error) {
+	t := w.now()
+	if entry.Timestamp.IsValid() {
+		t = entry.Timestamp.AsTime()
+	}
+	severity := Severity(entry.Severity)
+	level := fmt.Sprintf("%-9s", severity)
+	if code := severityColor(severity); w.color && code != "" {
+		level = "\x1b[" + code + "m" + level + "\x1b[0m"
+	}
+
+	message := entry.GetTextPayload()
+	if p := entry.GetJsonPayload(); p != nil {
+		fields := p.AsMap()
+		if m, ok := fields[w.messageKey].(string); ok {
+			message = m
+			delete(fields, w.messageKey)
+		}
+		if len(fields) > 0 {
+			data, err := json.Marshal(fields)
+			if err != nil {
+				return "", err
+			}
+			message = strings.TrimSpace(message + " " + string(data))
+		}
+	}
+	return t.Format("15:04:05.000") + " " + level + " " + message + "\n", nil
+}
+
+// severityColor is the ANSI color code for a severity, or empty for none.
+func severityColor(s Severity) string {
+	switch {
+	case s >= SeverityCritical:
+		return "1;31"
+	case s >= SeverityError:
+		return "31"
+	case s >= SeverityWarning:
+		return "33"
+	case s >= SeverityNotice:
+		return "36"
+	case s >= SeverityInfo:
+		return "32"
+	case s == SeverityDebug:
+		return "90"
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package cflog
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/logging/type"
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestConsoleWriter(t *testing.T) {
+	var buf bytes.Buffer
+	c, err := NewClient(context.Background(), WithConsole(&buf))
+	if err != nil {
+		t.Fatal("Client error", err)
+	}
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 6000000, time.UTC)
+
+	if err := c.LogAt(context.Background(), SeverityError, map[string]interface{}{"message": "failed", "id": 1}, ts); err != nil {
+		t.Fatal("Log error", err)
+	}
+	// A bytes.Buffer is not a terminal so there are no colors.
+	if expected := "03:04:05.006 ERROR     failed {\"id\":1}\n"; buf.String() != expected {
+		t.Fatalf("Unexpected line %q", buf.String())
+	}
+}
+
+func TestConsoleWriterClient(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	c, err := NewClient(context.Background(), WithConsole(&buf), WithMessageKey("msg"), WithClock(func() time.Time { return now }))
+	if err != nil {
+		t.Fatal("Client error", err)
+	}
+
+	if err := c.LogKV(context.Background(), SeverityInfo, "started", "id", 1); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if expected := "03:04:05.000 INFO      started {\"id\":1}\n"; buf.String() != expected {
+		t.Fatalf("Unexpected line %q", buf.String())
+	}
+}
+
+func TestConsoleWriterColor(t *testing.T) {
+	w := newConsoleWriter(&bytes.Buffer{})
+	w.color = true
+	entry := &loggingpb.LogEntry{
+		Severity:  ltype.LogSeverity_WARNING,
+		Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: "careful"},
+		Timestamp: timestamppb.New(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)),
+	}
+	line, err := w.format(entry)
+	if err != nil {
+		t.Fatal("Format error", err)
+	}
+	if expected := "03:04:05.000 \x1b[33mWARNING  \x1b[0m careful\n"; line != expected {
+		t.Fatalf("Unexpected line %q", line)
+	}
+
+	t.Setenv("NO_COLOR", "")
+	if newConsoleWriter(os.Stderr).color {
+		t.Fatal("Expected NO_COLOR to disable colors")
+	}
+}
@@ -0,0 +1,95 @@
+package cflog
+
+import (
+	"context"
+
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type fieldsKey struct{}
+
+// WithFields returns a copy of ctx carrying fields that Log adds to the JSON payload of every entry.
+// Fields from earlier calls are kept, with later calls overriding keys they share.
+func WithFields(ctx context.Context, fields map[string]interface{}) context.Context {
+	merged := map[string]interface{}{}
+	for k, v := range FieldsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// FieldsFromContext returns the fields added by WithFields.
+func FieldsFromContext(ctx context.Context) map[string]interface{} {
+	fields, _ := ctx.Value(fieldsKey{}).(map[string]interface{})
+	return fields
+}
+
+type labelsKey struct{}
+
+// WithLabels returns a copy of ctx carrying labels that Log adds to every entry. Unlike fields they are
+// indexed entry labels rather than part of the payload. Labels from earlier calls are kept, with later
+// calls overriding keys they share. They override the client's default labels and those passed to
+// LogWithLabels override them.
+func WithLabels(ctx context.Context, labels map[string]string) context.Context {
+	return context.WithValue(ctx, labelsKey{}, mergeLabels(LabelsFromContext(ctx), labels))
+}
+
+// LabelsFromContext returns the labels added by WithLabels.
+func LabelsFromContext(ctx context.Context) map[string]string {
+	labels, _ := ctx.Value(labelsKey{}).(map[string]string)
+	return labels
+}
+
+type loggerKey struct{}
+
+// NewContext returns a copy of ctx carrying l for FromContext, such as a client made for a request with With.
+func NewContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger stored by NewContext and true. Without one it returns a logger that
+// writes with the singleton client the package-level functions use, and false.
+func FromContext(ctx context.Context) (Logger, bool) {
+	if l, ok := ctx.Value(loggerKey{}).(Logger); ok {
+		return l, true
+	}
+	return defaultLogger{}, false
+}
+
+// defaultLogger is a Logger that writes with the singleton client.
+type defaultLogger struct{}
+
+func (defaultLogger) Log(ctx context.Context, severity Severity, payload interface{}) error {
+	return LogE(ctx, severity, payload)
+}
+
+// addEntryFields adds fields to the payload of an entry, turning a text payload into
+// a JSON payload with the text under messageKey. Fields already in the payload are kept.
+func addEntryFields(entry *loggingpb.LogEntry, fields map[string]interface{}, messageKey string) error {
+	converted := &loggingpb.LogEntry{}
+	if err := setEntryPayload(converted, fields); err != nil {
+		return err
+	}
+	extra := converted.GetJsonPayload().GetFields()
+
+	payload := entry.GetJsonPayload()
+	if payload == nil {
+		payload = &structpb.Struct{Fields: map[string]*structpb.Value{
+			messageKey: {Kind: &structpb.Value_StringValue{StringValue: entry.GetTextPayload()}},
+		}}
+		entry.Payload = &loggingpb.LogEntry_JsonPayload{JsonPayload: payload}
+	}
+	if payload.Fields == nil {
+		payload.Fields = map[string]*structpb.Value{}
+	}
+	for k, v := range extra {
+		if _, ok := payload.Fields[k]; !ok {
+			payload.Fields[k] = v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package cflog
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithFields(t *testing.T) {
+	ctx := WithFields(context.Background(), map[string]interface{}{"user": "u", "request": "r1"})
+	ctx = WithFields(ctx, map[string]interface{}{"request": "r2"})
+
+	fields := FieldsFromContext(ctx)
+	if fields["user"] != "u" || fields["request"] != "r2" {
+		t.Fatal("Unexpected fields", fields)
+	}
+
+	c := newClient()
+	entry, err := c.BuildEntry(ctx, SeverityInfo, "text")
+	if err != nil {
+		t.Fatal("Entry error", err)
+	}
+	payload := entry.GetJsonPayload().GetFields()
+	if payload["message"].GetStringValue() != "text" || payload["user"].GetStringValue() != "u" {
+		t.Fatal("Unexpected payload", payload)
+	}
+
+	entry, err = c.BuildEntry(ctx, SeverityInfo, map[string]interface{}{"user": "explicit"})
+	if err != nil {
+		t.Fatal("Entry error", err)
+	}
+	payload = entry.GetJsonPayload().GetFields()
+	if payload["user"].GetStringValue() != "explicit" || payload["request"].GetStringValue() != "r2" {
+		t.Fatal("Unexpected payload", payload)
+	}
+}
+
+func TestWithLabels(t *testing.T) {
+	ctx := WithLabels(context.Background(), map[string]string{"tenant": "t1", "env": "ctx"})
+	ctx = WithLabels(ctx, map[string]string{"tenant": "t2"})
+
+	labels := LabelsFromContext(ctx)
+	if len(labels) != 2 || labels["tenant"] != "t2" || labels["env"] != "ctx" {
+		t.Fatal("Unexpected labels", labels)
+	}
+
+	c, w := newFakeClient(WithDefaultLabels(map[string]string{"env": "default", "app": "a"}))
+	if err := c.LogWithLabels(ctx, SeverityInfo, "text", map[string]string{"tenant": "explicit"}); err != nil {
+		t.Fatal("Log error", err)
+	}
+	got := w.entries()[0]
+	if got.Labels["app"] != "a" || got.Labels["env"] != "ctx" || got.Labels["tenant"] != "explicit" {
+		t.Fatal("Unexpected labels", got.Labels)
+	}
+	if got.GetTextPayload() != "text" {
+		t.Fatal("Unexpected payload", got.Payload)
+	}
+}
+
+func TestLoggerContext(t *testing.T) {
+	c, w := newFakeClient()
+	ctx := NewContext(context.Background(), c.With(map[string]string{"request": "r1"}, ""))
+
+	l, ok := FromContext(ctx)
+	if !ok {
+		t.Fatal("Missing logger")
+	}
+	if err := l.Log(ctx, SeverityInfo, "text"); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if e := w.entries()[0]; e.Labels["request"] != "r1" {
+		t.Fatal("Unexpected labels", e.Labels)
+	}
+
+	singleton, sw := newFakeClient()
+	SetDefaultClient(singleton)
+	defer Close()
+	l, ok = FromContext(context.Background())
+	if ok {
+		t.Fatal("Unexpected logger", l)
+	}
+	if err := l.Log(context.Background(), SeverityInfo, "default"); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if entries := sw.entries(); len(entries) != 1 || entries[0].GetTextPayload() != "default" {
+		t.Fatal("Unexpected entries", entries)
+	}
+}
@@ -0,0 +1,118 @@
+package cflog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime/debug"
+)
+
+// reportedErrorEventType marks a payload as an error for Error Reporting.
+const reportedErrorEventType = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+// ReportError writes an ERROR entry for err formatted so Error Reporting groups it.
+// The payload has this shape, with the Go stack trace of the caller after the error message:
+//
+//	{
+//	  "@type": "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent",
+//	  "message": "<err.Error()>\n\n<stack trace>",
+//	  "serviceContext": {"service": "<service>", "version": "<version>"}
+//	}
+//
+// The service context defaults to the K_SERVICE or FUNCTION_NAME, and K_REVISION environment variables.
+// A nil err writes nothing.
+// https://cloud.google.com/error-reporting/docs/formatting-error-messages
+func (c Client) ReportError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	return c.log(ctx, SeverityError, c.reportedError(err.Error()), nil)
+}
+
+// RecoverAndLog recovers a panic and writes a CRITICAL entry for it formatted like ReportError,
+// with the panic value and the stack trace of the panicking goroutine.
+// It must be deferred directly, as in defer c.RecoverAndLog(ctx), for recover to stop the panic.
+// With WithRepanic the panic continues after it is logged, otherwise the function returns normally.
+// Errors writing the entry are passed to the handler set by SetErrorHandler.
+func (c Client) RecoverAndLog(ctx context.Context) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	payload := c.reportedError(fmt.Sprintf("panic: %v", r))
+	if err := c.log(ctx, SeverityCritical, payload, nil); err != nil {
+		handleError(fmt.Errorf("could not log panic %v: %w", r, err))
+	}
+	if c.repanic {
+		panic(r)
+	}
+}
+
+// reportedError builds an Error Reporting payload for the message with the current stack trace.
+func (c Client) reportedError(message string) map[string]interface{} {
+	serviceContext := map[string]interface{}{"service": c.serviceName}
+	if c.serviceVersion != "" {
+		serviceContext["version"] = c.serviceVersion
+	}
+	return map[string]interface{}{
+		"@type":          reportedErrorEventType,
+		"message":        message + "\n\n" + string(debug.Stack()),
+		"serviceContext": serviceContext,
+	}
+}
+
+// LogError writes an ERROR entry for err with a JSON payload holding its message under "message",
+// or the key set with WithMessageKey.
+// When an error in its chain has a StackTrace method, like those from github.com/pkg/errors,
+// the deepest stack trace is included under "stack_trace". A nil err writes nothing.
+func (c Client) LogError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	fields := errorFields(err, c.messageField())
+	if st := stackTrace(err); st != "" {
+		fields["stack_trace"] = st
+	}
+	return c.log(ctx, SeverityError, fields, nil)
+}
+
+// stackTrace returns the stack trace of the deepest error in the chain with a StackTrace method.
+// The method is found by reflection so any StackTrace type that formats with %+v works without
+// depending on the package that defines it.
+func stackTrace(err error) string {
+	var st string
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		m := reflect.ValueOf(e).MethodByName("StackTrace")
+		if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+			continue
+		}
+		if s := fmt.Sprintf("%+v", m.Call(nil)[0].Interface()); s != "" {
+			st = s
+		}
+	}
+	return st
+}
+
+// errorFields builds a JSON payload for an error with its message under messageKey.
+// Exported fields of any error in its chain are kept too, with outer errors taking precedence.
+func errorFields(err error, messageKey string) map[string]interface{} {
+	fields := map[string]interface{}{messageKey: err.Error()}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		data, mErr := json.Marshal(e)
+		if mErr != nil {
+			continue
+		}
+		var m map[string]interface{}
+		if json.Unmarshal(data, &m) != nil {
+			continue
+		}
+		for k, v := range m {
+			if _, ok := fields[k]; !ok {
+				fields[k] = v
+			}
+		}
+	}
+	return fields
+}
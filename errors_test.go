@@ -0,0 +1,134 @@
+package cflog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+)
+
+type codeError struct {
+	Code int `json:"code"`
+}
+
+func (e codeError) Error() string { return fmt.Sprintf("code %d", e.Code) }
+
+func TestErrorPayload(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    error
+		expected string
+		code     float64
+	}{
+		{name: "plain", input: errors.New("plain"), expected: "plain"},
+		{name: "wrapped", input: fmt.Errorf("outer: %w", errors.New("inner")), expected: "outer: inner"},
+		{name: "fields", input: codeError{Code: 404}, expected: "code 404", code: 404},
+		{name: "wrapped fields", input: fmt.Errorf("lookup: %w", codeError{Code: 500}), expected: "lookup: code 500", code: 500},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry := &loggingpb.LogEntry{}
+			if err := setEntryPayload(entry, test.input); err != nil {
+				t.Fatal("Set error", err)
+			}
+			fields := entry.GetJsonPayload().GetFields()
+			if m := fields["message"].GetStringValue(); m != test.expected {
+				t.Fatal("Unexpected message", m)
+			}
+			if c := fields["code"].GetNumberValue(); c != test.code {
+				t.Fatal("Unexpected code", c)
+			}
+		})
+	}
+}
+
+type stackError struct{ error }
+
+func (stackError) StackTrace() string { return "main.go:10" }
+
+func TestLogError(t *testing.T) {
+	c, w := newFakeClient()
+	err := fmt.Errorf("outer: %w", stackError{errors.New("inner")})
+	if lErr := c.LogError(context.Background(), err); lErr != nil {
+		t.Fatal("Log error", lErr)
+	}
+
+	entry := w.entries()[0]
+	if Severity(entry.Severity) != SeverityError {
+		t.Fatal("Unexpected severity", entry.Severity)
+	}
+	fields := entry.GetJsonPayload().GetFields()
+	if m := fields["message"].GetStringValue(); m != "outer: inner" {
+		t.Fatal("Unexpected message", m)
+	}
+	if st := fields["stack_trace"].GetStringValue(); st != "main.go:10" {
+		t.Fatal("Unexpected stack trace", st)
+	}
+
+	if st := stackTrace(errors.New("plain")); st != "" {
+		t.Fatal("Expected no stack trace", st)
+	}
+
+	if lErr := c.LogError(context.Background(), nil); lErr != nil {
+		t.Fatal("Log error", lErr)
+	}
+	if n := len(w.entries()); n != 1 {
+		t.Fatal("Unexpected entries for nil error", n)
+	}
+}
+
+func TestReportError(t *testing.T) {
+	c, w := newFakeClient(WithServiceContext("svc", "v1"))
+	if err := c.ReportError(context.Background(), errors.New("broken")); err != nil {
+		t.Fatal("Log error", err)
+	}
+
+	fields := w.entries()[0].GetJsonPayload().GetFields()
+	if typ := fields["@type"].GetStringValue(); typ != reportedErrorEventType {
+		t.Fatal("Unexpected type", typ)
+	}
+	if m := fields["message"].GetStringValue(); !strings.HasPrefix(m, "broken\n\ngoroutine ") {
+		t.Fatal("Expected message followed by a stack trace", m)
+	}
+	service := fields["serviceContext"].GetStructValue().GetFields()
+	if service["service"].GetStringValue() != "svc" || service["version"].GetStringValue() != "v1" {
+		t.Fatal("Unexpected service context", service)
+	}
+
+	if err := c.ReportError(context.Background(), nil); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if n := len(w.entries()); n != 1 {
+		t.Fatal("Unexpected entries for nil error", n)
+	}
+}
+
+func TestRecoverAndLog(t *testing.T) {
+	c, w := newFakeClient()
+	func() {
+		defer c.RecoverAndLog(context.Background())
+		panic("boom")
+	}()
+
+	entries := w.entries()
+	if len(entries) != 1 || Severity(entries[0].Severity) != SeverityCritical {
+		t.Fatal("Unexpected entries", entries)
+	}
+	message := entries[0].GetJsonPayload().GetFields()["message"].GetStringValue()
+	if !strings.HasPrefix(message, "panic: boom\n\n") || !strings.Contains(message, "TestRecoverAndLog") {
+		t.Fatal("Unexpected message", message)
+	}
+
+	c, _ = newFakeClient(WithRepanic(true))
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Fatal("Expected the panic to continue", r)
+		}
+	}()
+	defer c.RecoverAndLog(context.Background())
+	panic("boom")
+}
@@ -2,6 +2,7 @@ package cflog_test
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/mvndaai/cflog"
 )
@@ -34,3 +35,24 @@ func ExampleNewClient() {
 		//...
 	}
 }
+
+func ExampleNewClient_options() {
+	c, err := cflog.NewClient(context.Background(),
+		cflog.WithProjectID("my-project"),
+		cflog.WithLogName("my-service"),
+	)
+	if err != nil {
+		//...
+	}
+	defer c.Close()
+}
+
+func ExampleClient_BuildEntry() {
+	c := cflog.NewNopClient()
+	entry, err := c.BuildEntry(context.Background(), cflog.SeverityWarning, "Warning message")
+	if err != nil {
+		//...
+	}
+	fmt.Println(entry.Severity, entry.GetTextPayload())
+	// Output: WARNING Warning message
+}
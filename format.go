@@ -0,0 +1,60 @@
+package cflog
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// formatterMap holds the formatter of each type by its reflect.Type.
+type formatterMap map[reflect.Type]func(interface{}) interface{}
+
+var (
+	// formatters holds a formatterMap that is replaced rather than changed so lookups need no lock.
+	formatters   atomic.Value
+	formattersMu sync.Mutex
+)
+
+func init() {
+	formatters.Store(formatterMap{
+		reflect.TypeOf(time.Duration(0)): func(v interface{}) interface{} { return v.(time.Duration).String() },
+	})
+}
+
+// RegisterFormatter makes payloads of type T, and values of type T in a map[string]interface{} or
+// []interface{} such as the fields of LogFields, LogKV, WithFields, and the slog and logr adapters, be
+// logged as what format returns for them, which should be a string, number, bool, or generic map or slice.
+// Values inside structs and other types are left to encoding/json, as they would be without a formatter.
+// A time.Duration is formatted as its String form, such as "1.5s", unless it is given another formatter.
+// A nil format removes the formatter of T. T should not be an interface since values are matched by
+// their concrete type. It is safe to call while logging but is meant to be called during initialization.
+func RegisterFormatter[T any](format func(T) interface{}) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+	old := formatters.Load().(formatterMap)
+	m := make(formatterMap, len(old)+1)
+	for k, f := range old {
+		m[k] = f
+	}
+	if format == nil {
+		delete(m, t)
+	} else {
+		m[t] = func(v interface{}) interface{} { return format(v.(T)) }
+	}
+	formatters.Store(m)
+}
+
+// formatValue returns what the formatter registered for the type of v returns, reporting whether there is one.
+func formatValue(v interface{}) (interface{}, bool) {
+	if v == nil {
+		return nil, false
+	}
+	f, ok := formatters.Load().(formatterMap)[reflect.TypeOf(v)]
+	if !ok {
+		return v, false
+	}
+	return f(v), true
+}
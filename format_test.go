@@ -0,0 +1,87 @@
+package cflog
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+)
+
+func TestDurationPayload(t *testing.T) {
+	tests := []struct {
+		name  string
+		input interface{}
+		text  string
+		json  string
+	}{
+		{name: "text", input: 1500 * time.Millisecond, text: "1.5s"},
+		{name: "field", input: map[string]interface{}{"latency": 250 * time.Microsecond}, json: `{"latency":"250µs"}`},
+		{name: "list", input: []interface{}{time.Minute}, json: `{"values":["1m0s"]}`},
+		{name: "time", input: map[string]interface{}{"at": time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}, json: `{"at":"2020-01-02T03:04:05Z"}`},
+		{name: "mixed map", input: map[string]interface{}{"at": time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), "took": time.Second}, json: `{"at":"2020-01-02T03:04:05Z","took":"1s"}`},
+		// Values inside structs and typed maps are left to encoding/json.
+		{name: "struct", input: struct {
+			D time.Duration `json:"d"`
+		}{D: 1500 * time.Millisecond}, json: `{"d":1500000000}`},
+		{name: "struct with NaN", input: struct {
+			D time.Duration `json:"d"`
+			F float64       `json:"f"`
+		}{D: 1500 * time.Millisecond, F: math.NaN()}, json: `{"d":1500000000,"f":"NaN"}`},
+		{name: "nested struct in map", input: map[string]interface{}{"timing": struct{ Total time.Duration }{Total: time.Minute}, "took": time.Minute, "ch": nil}, json: `{"ch":null,"timing":{"Total":60000000000},"took":"1m0s"}`},
+		{name: "typed map", input: map[string]time.Duration{"took": time.Second}, json: `{"took":1000000000}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry := &loggingpb.LogEntry{}
+			if err := setEntryPayload(entry, test.input); err != nil {
+				t.Fatal("Set error", err)
+			}
+			if test.json != "" {
+				if got := payloadJSON(t, entry.GetJsonPayload()); got != test.json {
+					t.Fatal("Unexpected payload", got)
+				}
+				return
+			}
+			if entry.GetTextPayload() != test.text {
+				t.Fatal("Unexpected payload", entry.Payload)
+			}
+		})
+	}
+
+	c, w := newFakeClient()
+	if err := c.LogKV(context.Background(), SeverityInfo, "done", "took", 2*time.Second); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if got := payloadJSON(t, w.entries()[0].GetJsonPayload()); got != `{"message":"done","took":"2s"}` {
+		t.Fatal("Unexpected payload", got)
+	}
+}
+
+type testCents int64
+
+func TestRegisterFormatter(t *testing.T) {
+	RegisterFormatter(func(c testCents) interface{} { return float64(c) / 100 })
+	defer RegisterFormatter[testCents](nil)
+	RegisterFormatter(func(d time.Duration) interface{} { return d.Seconds() })
+	defer RegisterFormatter(func(d time.Duration) interface{} { return d.String() })
+
+	entry := &loggingpb.LogEntry{}
+	if err := setEntryPayload(entry, map[string]interface{}{"price": testCents(1250), "took": time.Second}); err != nil {
+		t.Fatal("Set error", err)
+	}
+	if got := payloadJSON(t, entry.GetJsonPayload()); got != `{"price":12.5,"took":1}` {
+		t.Fatal("Unexpected payload", got)
+	}
+
+	RegisterFormatter[testCents](nil)
+	entry = &loggingpb.LogEntry{}
+	if err := setEntryPayload(entry, map[string]interface{}{"price": testCents(1250)}); err != nil {
+		t.Fatal("Set error", err)
+	}
+	if got := payloadJSON(t, entry.GetJsonPayload()); got != `{"price":1250}` {
+		t.Fatal("Unexpected payload", got)
+	}
+}
@@ -1,10 +1,24 @@
 module github.com/mvndaai/cflog
 
-go 1.12
+go 1.21
 
 require (
 	cloud.google.com/go v0.37.4
-	github.com/golang/protobuf v1.3.1
-	github.com/micro/protobuf v0.0.0-20180321161605-ebd3be6d4fdb
+	github.com/go-logr/logr v1.4.2
+	github.com/googleapis/gax-go/v2 v2.0.4
+	google.golang.org/api v0.3.1
 	google.golang.org/genproto v0.0.0-20190415143225-d1146b9035b9
+	google.golang.org/grpc v1.19.0
+	google.golang.org/protobuf v1.34.2
+)
+
+require (
+	github.com/golang/protobuf v1.5.4 // indirect
+	github.com/hashicorp/golang-lru v0.5.0 // indirect
+	go.opencensus.io v0.20.1 // indirect
+	golang.org/x/net v0.0.0-20190311183353-d8887717615a // indirect
+	golang.org/x/oauth2 v0.0.0-20190226205417-e64efc72b421 // indirect
+	golang.org/x/sys v0.0.0-20190215142949-d0b11bdaac8a // indirect
+	golang.org/x/text v0.3.1-0.20180807135948-17ff2d5776d2 // indirect
+	google.golang.org/appengine v1.4.0 // indirect
 )
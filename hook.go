@@ -0,0 +1,31 @@
+package cflog
+
+// Hook is notified of what a client does with its entries, for monitoring the logger itself,
+// e.g. by counting them in Prometheus or OpenCensus. Its methods can be called concurrently.
+type Hook interface {
+	// OnWrite is called after n entries are written to the logging API in one request.
+	OnWrite(n int)
+	// OnDrop is called for each entry dropped by the minimum severity, the sampler,
+	// or a full or closed asynchronous queue.
+	OnDrop(severity Severity)
+	// OnError is called when a write fails after any retries.
+	OnError(err error)
+}
+
+func (c Client) onWrite(n int) {
+	if c.hook != nil {
+		c.hook.OnWrite(n)
+	}
+}
+
+func (c Client) onDrop(s Severity) {
+	if c.hook != nil {
+		c.hook.OnDrop(s)
+	}
+}
+
+func (c Client) onError(err error) {
+	if c.hook != nil {
+		c.hook.OnError(err)
+	}
+}
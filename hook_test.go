@@ -0,0 +1,88 @@
+package cflog
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// countingHook counts what it is notified of.
+type countingHook struct {
+	mu      sync.Mutex
+	written int
+	dropped []Severity
+	errs    []error
+}
+
+func (h *countingHook) OnWrite(n int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.written += n
+}
+
+func (h *countingHook) OnDrop(s Severity) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.dropped = append(h.dropped, s)
+}
+
+func (h *countingHook) OnError(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.errs = append(h.errs, err)
+}
+
+func TestHook(t *testing.T) {
+	ctx := context.Background()
+	h := &countingHook{}
+	c, w := newFakeClient(WithHook(h), WithMinSeverity(SeverityInfo))
+
+	if err := c.LogBatch(ctx, SeverityInfo, []interface{}{"a", "b"}); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if err := c.Debug(ctx, "skipped"); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if err := c.LogBatch(ctx, SeverityDebug, []interface{}{"c", "d"}); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if err := c.LogEntries(ctx, []Entry{{Severity: SeverityNotice, Payload: "e"}, {Severity: SeverityDebug, Payload: "f"}}); err != nil {
+		t.Fatal("Log error", err)
+	}
+	writeErr := errors.New("unavailable")
+	w.errs = []error{writeErr}
+	if err := c.Info(ctx, "failed"); err != writeErr {
+		t.Fatal("Unexpected error", err)
+	}
+
+	if h.written != 3 {
+		t.Fatal("Unexpected written count", h.written)
+	}
+	if len(h.dropped) != 4 {
+		t.Fatal("Unexpected dropped", h.dropped)
+	}
+	for _, s := range h.dropped {
+		if s != SeverityDebug {
+			t.Fatal("Unexpected dropped", h.dropped)
+		}
+	}
+	if len(h.errs) != 1 || h.errs[0] != writeErr {
+		t.Fatal("Unexpected errors", h.errs)
+	}
+}
+
+func TestHookAsyncDrop(t *testing.T) {
+	h := &countingHook{}
+	// Without a writer nothing takes entries off the queue so the second entry is dropped.
+	c := newClient(WithHook(h), WithAsync(1))
+
+	c.Info(context.Background(), "queued")
+	c.Warn(context.Background(), "dropped")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.dropped) != 1 || h.dropped[0] != SeverityWarning {
+		t.Fatal("Unexpected dropped", h.dropped)
+	}
+}
@@ -0,0 +1,121 @@
+package cflog
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/logging/type"
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// LogHTTPRequest creates a log like Log with the httpRequest field set so the Logs Explorer renders it specially.
+// Use HTTPRequest to build the field from a standard *http.Request.
+func (c Client) LogHTTPRequest(ctx context.Context, severity Severity, payload interface{}, req *ltype.HttpRequest) error {
+	return c.log(ctx, severity, payload, func(entry *loggingpb.LogEntry) error {
+		entry.HttpRequest = req
+		return nil
+	})
+}
+
+// HTTPRequest builds the httpRequest field of a log entry from a request, its response status, and its latency.
+// A zero status or latency is left out of the entry.
+func HTTPRequest(r *http.Request, status int, latency time.Duration) *ltype.HttpRequest {
+	req := &ltype.HttpRequest{
+		RequestMethod: r.Method,
+		RequestUrl:    r.URL.String(),
+		Status:        int32(status),
+		UserAgent:     r.UserAgent(),
+		RemoteIp:      remoteIP(r),
+		Referer:       r.Referer(),
+		Protocol:      r.Proto,
+	}
+	if r.ContentLength > 0 {
+		req.RequestSize = r.ContentLength
+	}
+	if latency > 0 {
+		req.Latency = durationpb.New(latency)
+	}
+	return req
+}
+
+// remoteIP returns the client IP, preferring the first X-Forwarded-For hop set by Google's front ends.
+func remoteIP(r *http.Request) string {
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		return strings.TrimSpace(strings.Split(fwd, ",")[0])
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// HTTPMiddleware wraps a handler to write an entry for each request with its httpRequest field set.
+// The trace from the X-Cloud-Trace-Context header is added to the request context with ContextWithTrace
+// so entries logged while handling it are grouped with the request, and c is added with NewContext
+// so handlers can get it with FromContext.
+// Responses with a 5xx status are logged as Error, 4xx as Warning, and anything else as Info.
+// Errors writing the entry are passed to the handler set by SetErrorHandler.
+func (c Client) HTTPMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := c.now()
+		ctx := NewContext(r.Context(), c)
+		if t := ParseTraceHeader(r.Header.Get(TraceHeader)); t.TraceID != "" {
+			ctx = ContextWithTrace(ctx, t)
+		}
+		r = r.WithContext(ctx)
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		// The request context is cancelled if the client disconnects but the entry is still wanted.
+		ctx = context.WithoutCancel(r.Context())
+		payload := r.Method + " " + r.URL.Path
+		if err := c.LogHTTPRequest(ctx, statusSeverity(status), payload, HTTPRequest(r, status, c.now().Sub(start))); err != nil {
+			handleError(fmt.Errorf("could not log request %q: %w", payload, err))
+		}
+	})
+}
+
+// statusSeverity is the severity of an entry for a response with the status.
+func statusSeverity(status int) Severity {
+	switch {
+	case status >= 500:
+		return SeverityError
+	case status >= 400:
+		return SeverityWarning
+	}
+	return SeverityInfo
+}
+
+// statusRecorder is a ResponseWriter that keeps the status written.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the wrapped ResponseWriter so http.ResponseController can reach it.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,84 @@
+package cflog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPRequest(t *testing.T) {
+	r := httptest.NewRequest("POST", "https://example.com/path?q=1", nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("User-Agent", "test-agent")
+
+	req := HTTPRequest(r, 201, 1500*time.Millisecond)
+	if req.RequestMethod != "POST" || req.RequestUrl != "https://example.com/path?q=1" {
+		t.Fatal("Unexpected request", req.RequestMethod, req.RequestUrl)
+	}
+	if req.Status != 201 || req.UserAgent != "test-agent" || req.RemoteIp != "10.0.0.1" {
+		t.Fatal("Unexpected fields", req.Status, req.UserAgent, req.RemoteIp)
+	}
+	if req.Latency.Seconds != 1 || req.Latency.Nanos != 500000000 {
+		t.Fatal("Unexpected latency", req.Latency)
+	}
+
+	r.Header.Set("X-Forwarded-For", "203.0.113.1, 10.0.0.2")
+	if ip := HTTPRequest(r, 0, 0).RemoteIp; ip != "203.0.113.1" {
+		t.Fatal("Unexpected forwarded IP", ip)
+	}
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	c, w := newFakeClient(WithProjectID("p"))
+	var handlerTrace Trace
+	var handlerLogger bool
+	h := c.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlerTrace = TraceFromContext(r.Context())
+		_, handlerLogger = FromContext(r.Context())
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	r := httptest.NewRequest("GET", "https://example.com/missing", nil)
+	r.Header.Set(TraceHeader, "105445aa7843bc8bf206b120001000/1;o=1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if handlerTrace.TraceID != "105445aa7843bc8bf206b120001000" || !handlerLogger {
+		t.Fatal("Unexpected handler context", handlerTrace, handlerLogger)
+	}
+	entries := w.entries()
+	if len(entries) != 1 {
+		t.Fatal("Unexpected entries", entries)
+	}
+	e := entries[0]
+	if Severity(e.Severity) != SeverityWarning || e.GetTextPayload() != "GET /missing" {
+		t.Fatal("Unexpected entry", e.Severity, e.Payload)
+	}
+	if e.HttpRequest.Status != http.StatusNotFound || e.Trace != "projects/p/traces/105445aa7843bc8bf206b120001000" {
+		t.Fatal("Unexpected request fields", e.HttpRequest, e.Trace)
+	}
+
+	h = c.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if e := w.entries()[1]; Severity(e.Severity) != SeverityInfo || e.HttpRequest.Status != http.StatusOK {
+		t.Fatal("Unexpected entry", e.Severity, e.HttpRequest)
+	}
+}
+
+func TestHTTPMiddlewareLatency(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	calls := 0
+	clock := func() time.Time {
+		calls++
+		return start.Add(time.Duration(calls-1) * 250 * time.Millisecond)
+	}
+	c, w := newFakeClient(WithClock(clock))
+
+	h := c.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if latency := w.entries()[0].HttpRequest.Latency.AsDuration(); latency != 250*time.Millisecond {
+		t.Fatal("Unexpected latency", latency)
+	}
+}
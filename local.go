@@ -0,0 +1,140 @@
+package cflog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	gax "github.com/googleapis/gax-go/v2"
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// jsonLineWriter is an EntryWriter that writes each entry as a line of JSON in the format
+// Cloud Logging ingests from stdout and stderr.
+// https://cloud.google.com/logging/docs/structured-logging#special-payload-fields
+type jsonLineWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *jsonLineWriter) WriteLogEntries(ctx context.Context, req *loggingpb.WriteLogEntriesRequest, opts ...gax.CallOption) (*loggingpb.WriteLogEntriesResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	enc := json.NewEncoder(w.w)
+	for _, entry := range req.Entries {
+		line, err := structuredEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(line); err != nil {
+			return nil, err
+		}
+	}
+	return &loggingpb.WriteLogEntriesResponse{}, nil
+}
+
+// dryRunWriter is an EntryWriter that writes each entry as a line of its LogEntry JSON,
+// exactly as it would be sent to the logging API.
+type dryRunWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *dryRunWriter) WriteLogEntries(ctx context.Context, req *loggingpb.WriteLogEntriesRequest, opts ...gax.CallOption) (*loggingpb.WriteLogEntriesResponse, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, entry := range req.Entries {
+		data, err := protojson.Marshal(protoadapt.MessageV2Of(entry))
+		if err != nil {
+			return nil, err
+		}
+		// protojson varies its spacing so compact it for stable output.
+		var line bytes.Buffer
+		if err := json.Compact(&line, data); err != nil {
+			return nil, err
+		}
+		line.WriteByte('\n')
+		if _, err := w.w.Write(line.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	return &loggingpb.WriteLogEntriesResponse{}, nil
+}
+
+// structuredEntry converts an entry into the special JSON fields Cloud Logging reads from a line of output.
+func structuredEntry(entry *loggingpb.LogEntry) (map[string]interface{}, error) {
+	line := map[string]interface{}{}
+	if p := entry.GetJsonPayload(); p != nil {
+		fields, err := protoToMap(p)
+		if err != nil {
+			return nil, err
+		}
+		line = fields
+	} else {
+		line["message"] = entry.GetTextPayload()
+	}
+
+	line["severity"] = Severity(entry.Severity).String()
+	if len(entry.Labels) > 0 {
+		line["logging.googleapis.com/labels"] = entry.Labels
+	}
+	if entry.InsertId != "" {
+		line["logging.googleapis.com/insertId"] = entry.InsertId
+	}
+	if entry.Trace != "" {
+		line["logging.googleapis.com/trace"] = entry.Trace
+		line["logging.googleapis.com/spanId"] = entry.SpanId
+		line["logging.googleapis.com/trace_sampled"] = entry.TraceSampled
+	}
+	if entry.Timestamp != nil {
+		if entry.Timestamp.IsValid() {
+			line["timestamp"] = entry.Timestamp.AsTime()
+		}
+	}
+	if entry.SourceLocation != nil {
+		loc, err := protoToMap(entry.SourceLocation)
+		if err != nil {
+			return nil, err
+		}
+		line["logging.googleapis.com/sourceLocation"] = loc
+	}
+	if entry.HttpRequest != nil {
+		req, err := protoToMap(entry.HttpRequest)
+		if err != nil {
+			return nil, err
+		}
+		line["httpRequest"] = req
+	}
+	return line, nil
+}
+
+// protoToMap converts a message into its proto JSON form as a map.
+func protoToMap(pb protoadapt.MessageV1) (map[string]interface{}, error) {
+	data, err := protojson.Marshal(protoadapt.MessageV2Of(pb))
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// onGCP reports whether the environment variables set by Google Cloud runtimes are present.
+func onGCP() bool {
+	return firstEnv("GCP_PROJECT", "GOOGLE_CLOUD_PROJECT", "FUNCTION_NAME", "K_SERVICE") != ""
+}
+
+// localClient creates a client that writes structured JSON lines to stderr.
+// It is a variable so tests can see when the singleton is created.
+var localClient = func() Client {
+	return NewClientWithWriter(&jsonLineWriter{w: os.Stderr})
+}
@@ -0,0 +1,68 @@
+package cflog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestWithOutput(t *testing.T) {
+	ctx := ContextWithTrace(context.Background(), Trace{TraceID: "abc", SpanID: "0000000000000001"})
+	var buf bytes.Buffer
+	c, err := NewClient(ctx, WithOutput(&buf), WithProjectID("p"))
+	if err != nil {
+		t.Fatal("Client error", err)
+	}
+
+	if err := c.LogWithLabels(ctx, SeverityWarning, "text", map[string]string{"k": "v"}); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if err := c.Error(ctx, struct{ M string }{M: "json"}); err != nil {
+		t.Fatal("Log error", err)
+	}
+
+	dec := json.NewDecoder(&buf)
+	var text, structured map[string]interface{}
+	if err := dec.Decode(&text); err != nil {
+		t.Fatal("Decode error", err)
+	}
+	if err := dec.Decode(&structured); err != nil {
+		t.Fatal("Decode error", err)
+	}
+
+	if text["message"] != "text" || text["severity"] != "WARNING" {
+		t.Fatal("Unexpected text line", text)
+	}
+	if labels, _ := text["logging.googleapis.com/labels"].(map[string]interface{}); labels["k"] != "v" {
+		t.Fatal("Unexpected labels", text)
+	}
+	if text["logging.googleapis.com/trace"] != "projects/p/traces/abc" {
+		t.Fatal("Unexpected trace", text)
+	}
+	if structured["M"] != "json" || structured["severity"] != "ERROR" {
+		t.Fatal("Unexpected JSON line", structured)
+	}
+}
+
+func TestWithDryRunOutput(t *testing.T) {
+	var buf bytes.Buffer
+	c, err := NewClient(context.Background(), WithDryRunOutput(&buf), WithProjectID("p"), WithLogName("dry"))
+	if err != nil {
+		t.Fatal("Client error", err)
+	}
+	if err := c.Warn(context.Background(), map[string]interface{}{"k": "v"}); err != nil {
+		t.Fatal("Log error", err)
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatal("Unmarshal error", err, buf.String())
+	}
+	if line["logName"] != "projects/p/logs/dry" || line["severity"] != "WARNING" {
+		t.Fatal("Unexpected entry", line)
+	}
+	if payload, _ := line["jsonPayload"].(map[string]interface{}); payload["k"] != "v" {
+		t.Fatal("Unexpected payload", line["jsonPayload"])
+	}
+}
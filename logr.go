@@ -0,0 +1,105 @@
+package cflog
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+)
+
+// logrNameKey is the JSON payload field holding the name of a logr.Logger.
+const logrNameKey = "logger"
+
+// logSink is a logr.LogSink that writes with a Client.
+type logSink struct {
+	client Client
+	name   string
+	values map[string]interface{}
+}
+
+// NewLogSink returns a logr.LogSink that writes each log as an entry with a JSON payload, so code using
+// github.com/go-logr/logr can log through c with logr.New(cflog.NewLogSink(c)).
+// The message is under the client's message key, "message" by default, key value pairs are fields,
+// and names joined by "/" are under "logger".
+// V(0) is Info and higher verbosity is Debug. Errors are written as Error with the error under "error".
+// Write errors are passed to the handler set by SetErrorHandler.
+func NewLogSink(c Client) logr.LogSink {
+	return &logSink{client: c}
+}
+
+var _ logr.CallDepthLogSink = &logSink{}
+
+// Init implements logr.LogSink and skips the logr frames above the sink when finding the source location.
+func (s *logSink) Init(info logr.RuntimeInfo) {
+	s.client.callDepth = info.CallDepth
+}
+
+// Enabled implements logr.LogSink and reports whether the client's minimum severity allows the level.
+func (s *logSink) Enabled(level int) bool {
+	return int32(logrSeverity(level)) >= int32(s.client.minSeverity)
+}
+
+// Info implements logr.LogSink.
+func (s *logSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	s.write(logrSeverity(level), msg, nil, keysAndValues)
+}
+
+// Error implements logr.LogSink.
+func (s *logSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	s.write(SeverityError, msg, err, keysAndValues)
+}
+
+// WithValues implements logr.LogSink.
+func (s *logSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	values := make(map[string]interface{}, len(s.values)+len(keysAndValues)/2)
+	for k, v := range s.values {
+		values[k] = v
+	}
+	for k, v := range kvFields(keysAndValues) {
+		values[k] = v
+	}
+	return &logSink{client: s.client, name: s.name, values: values}
+}
+
+// WithCallDepth implements logr.CallDepthLogSink for helpers that wrap a logr.Logger.
+func (s *logSink) WithCallDepth(depth int) logr.LogSink {
+	c := s.client
+	c.callDepth += depth
+	return &logSink{client: c, name: s.name, values: s.values}
+}
+
+// WithName implements logr.LogSink.
+func (s *logSink) WithName(name string) logr.LogSink {
+	if s.name != "" {
+		name = s.name + "/" + name
+	}
+	return &logSink{client: s.client, name: name, values: s.values}
+}
+
+func (s *logSink) write(severity Severity, msg string, err error, keysAndValues []interface{}) {
+	fields := kvFields(keysAndValues)
+	for k, v := range s.values {
+		if _, ok := fields[k]; !ok {
+			fields[k] = v
+		}
+	}
+	if s.name != "" {
+		fields[logrNameKey] = s.name
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+	}
+	fields[s.client.messageField()] = msg
+
+	if wErr := s.client.log(context.Background(), severity, fields, nil); wErr != nil {
+		handleError(fmt.Errorf("could not log %q: %w", msg, wErr))
+	}
+}
+
+// logrSeverity maps a logr verbosity onto a Severity.
+func logrSeverity(level int) Severity {
+	if level > 0 {
+		return SeverityDebug
+	}
+	return SeverityInfo
+}
@@ -0,0 +1,43 @@
+package cflog
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+)
+
+func TestLogSink(t *testing.T) {
+	c, w := newFakeClient()
+	l := logr.New(NewLogSink(c)).WithName("controller").WithName("pods").WithValues("namespace", "default")
+
+	l.Info("reconciled", "pod", "p1")
+	l.V(1).Info("details")
+	l.Error(errors.New("boom"), "failed", "namespace", "kube-system")
+
+	entries := w.entries()
+	if len(entries) != 3 {
+		t.Fatal("Unexpected entries", entries)
+	}
+	tests := []struct {
+		severity Severity
+		expected string
+	}{
+		{severity: SeverityInfo, expected: `{"logger":"controller/pods","message":"reconciled","namespace":"default","pod":"p1"}`},
+		{severity: SeverityDebug, expected: `{"logger":"controller/pods","message":"details","namespace":"default"}`},
+		{severity: SeverityError, expected: `{"error":"boom","logger":"controller/pods","message":"failed","namespace":"kube-system"}`},
+	}
+	for i, test := range tests {
+		if s := Severity(entries[i].Severity); s != test.severity {
+			t.Fatal("Unexpected severity", i, s)
+		}
+		if got := payloadJSON(t, entries[i].GetJsonPayload()); got != test.expected {
+			t.Fatal("Unexpected payload", i, got)
+		}
+	}
+
+	c, _ = newFakeClient(WithMinSeverity(SeverityInfo))
+	if l := logr.New(NewLogSink(c)); l.V(1).Enabled() || !l.Enabled() {
+		t.Fatal("Unexpected enabled levels")
+	}
+}
@@ -0,0 +1,295 @@
+package cflog
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// nonFiniteString is how NaN and infinite floats are written since JSON has no numbers for them.
+func nonFiniteString(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "+Inf"
+	}
+	return "-Inf"
+}
+
+// isNonFinite reports whether json.Marshal rejected a NaN or infinite float rather than a cycle.
+func isNonFinite(err *json.UnsupportedValueError) bool {
+	switch err.Str {
+	case "NaN", "+Inf", "-Inf":
+		return true
+	}
+	return false
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonNumberType    = reflect.TypeOf(json.Number(""))
+	bigIntType        = reflect.TypeOf(big.Int{})
+)
+
+// visit identifies a pointer, map, or slice that is being converted, to find cycles.
+type visit struct {
+	ptr uintptr
+	len int
+	typ reflect.Type
+}
+
+// genericValue converts v into the values a generic map or slice holds, following the encoding/json
+// rules for marshalers and struct fields, so NaN and infinite floats, which json.Marshal rejects, are
+// kept for newValue to write as strings. Seen holds the values being converted so a cycle is an error,
+// as it is for json.Marshal, instead of recursing forever.
+func genericValue(v reflect.Value, seen map[visit]bool) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	// Numbers newValue converts exactly are kept as is instead of becoming a string or an empty object.
+	switch v.Type() {
+	case jsonNumberType:
+		return json.Number(v.String()), nil
+	case bigIntType:
+		i := v.Interface().(big.Int)
+		return &i, nil
+	}
+	// Like json.Marshal, methods with pointer receivers are used when the value is addressable.
+	if v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface && v.CanAddr() {
+		if pt := reflect.PointerTo(v.Type()); pt.Implements(jsonMarshalerType) || pt.Implements(textMarshalerType) {
+			v = v.Addr()
+		}
+	}
+	if v.Type().Implements(jsonMarshalerType) || v.Type().Implements(textMarshalerType) {
+		if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+			return nil, nil
+		}
+		data, err := json.Marshal(v.Interface())
+		if err != nil {
+			return nil, err
+		}
+		return json.RawMessage(data), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		key := visit{ptr: v.Pointer(), typ: v.Type()}
+		if v.Kind() == reflect.Slice {
+			key.len = v.Len()
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("cannot convert %s: encountered a cycle", v.Type())
+		}
+		seen[key] = true
+		defer delete(seen, key)
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return genericValue(v.Elem(), seen)
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), nil
+	case reflect.Float32:
+		return float32(v.Float()), nil
+	case reflect.Float64:
+		return v.Float(), nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 && v.Kind() == reflect.Slice {
+			return v.Bytes(), nil
+		}
+		l := make([]interface{}, v.Len())
+		for i := range l {
+			item, err := genericValue(v.Index(i), seen)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = item
+		}
+		return l, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			item, err := genericValue(iter.Value(), seen)
+			if err != nil {
+				return nil, err
+			}
+			key, err := mapKey(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			m[key] = item
+		}
+		return m, nil
+	case reflect.Struct:
+		m := map[string]interface{}{}
+		if err := addStructFields(m, v, seen); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("cannot convert %s", v.Type())
+}
+
+// mapKey returns the name json.Marshal gives a map key.
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if m, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", nil
+		}
+		text, err := m.MarshalText()
+		return string(text), err
+	}
+	return fmt.Sprint(k.Interface()), nil
+}
+
+// structField is a field json.Marshal writes for a struct, found by its index through embedded structs.
+type structField struct {
+	name   string
+	index  []int
+	tagged bool
+	opts   string
+}
+
+// structFields returns the fields json.Marshal writes for t. As in encoding/json, a field hides fields
+// of the same name in more deeply embedded structs, and of several at the same depth only the one with
+// a JSON tag is written, so the name is left out when there is no such field or more than one.
+func structFields(t reflect.Type) []structField {
+	byName := map[string][]structField{}
+	var names []string
+	collectFields(t, nil, map[reflect.Type]bool{t: true}, byName, &names)
+
+	fields := make([]structField, 0, len(names))
+	for _, name := range names {
+		candidates := byName[name]
+		depth := len(candidates[0].index)
+		for _, f := range candidates {
+			if len(f.index) < depth {
+				depth = len(f.index)
+			}
+		}
+		var dominant []structField
+		var tagged []structField
+		for _, f := range candidates {
+			if len(f.index) != depth {
+				continue
+			}
+			dominant = append(dominant, f)
+			if f.tagged {
+				tagged = append(tagged, f)
+			}
+		}
+		switch {
+		case len(dominant) == 1:
+			fields = append(fields, dominant[0])
+		case len(tagged) == 1:
+			fields = append(fields, tagged[0])
+		}
+	}
+	return fields
+}
+
+// collectFields adds the fields of t and of the structs it embeds to byName, and each new name to names.
+// Path holds the embedded struct types being collected so a struct that embeds itself does not recurse forever.
+func collectFields(t reflect.Type, index []int, path map[reflect.Type]bool, byName map[string][]structField, names *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		fieldIndex := append(append([]int(nil), index...), i)
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if !path[ft] {
+					path[ft] = true
+					collectFields(ft, fieldIndex, path, byName, names)
+					delete(path, ft)
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		tagged := name != ""
+		if !tagged {
+			name = f.Name
+		}
+		if _, ok := byName[name]; !ok {
+			*names = append(*names, name)
+		}
+		byName[name] = append(byName[name], structField{name: name, index: fieldIndex, tagged: tagged, opts: opts})
+	}
+}
+
+// addStructFields adds the fields json.Marshal would write for a struct to m.
+func addStructFields(m map[string]interface{}, v reflect.Value, seen map[visit]bool) error {
+	for _, f := range structFields(v.Type()) {
+		fv, err := v.FieldByIndexErr(f.index)
+		if err != nil {
+			// The field is in a nil embedded pointer, which json.Marshal also leaves out.
+			continue
+		}
+		if strings.Contains(","+f.opts+",", ",omitempty,") && isEmptyValue(fv) {
+			continue
+		}
+		if strings.Contains(","+f.opts+",", ",string,") {
+			// Leave quoting values to json.Marshal rather than repeating its rules.
+			return fmt.Errorf("cannot convert %s field %s with the string option", v.Type(), f.name)
+		}
+
+		value, err := genericValue(fv, seen)
+		if err != nil {
+			return err
+		}
+		m[f.name] = value
+	}
+	return nil
+}
+
+// isEmptyValue reports whether omitempty leaves v out, matching encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package cflog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+)
+
+func TestNonFiniteFloats(t *testing.T) {
+	type inner struct {
+		NaN    float64 `json:"nan"`
+		PosInf float64 `json:"pos_inf"`
+		NegInf float32 `json:"neg_inf"`
+		Skip   float64 `json:"-"`
+	}
+	type Embedded struct {
+		E int `json:"e"`
+	}
+	type outer struct {
+		Embedded
+		Name   string          `json:"name"`
+		Inner  inner           `json:"inner"`
+		List   []float64       `json:"list"`
+		Map    map[int]float64 `json:"map"`
+		Empty  string          `json:"empty,omitempty"`
+		hidden float64
+	}
+
+	tests := []struct {
+		name     string
+		input    interface{}
+		expected string
+	}{
+		{
+			name: "nested struct",
+			input: outer{
+				Embedded: Embedded{E: 1},
+				Name:     "n",
+				Inner:    inner{NaN: math.NaN(), PosInf: math.Inf(1), NegInf: float32(math.Inf(-1))},
+				List:     []float64{1.5, math.NaN()},
+				Map:      map[int]float64{1: math.Inf(1)},
+				hidden:   math.NaN(),
+			},
+			expected: `{"e":1,"inner":{"nan":"NaN","neg_inf":"-Inf","pos_inf":"+Inf"},"list":[1.5,"NaN"],"map":{"1":"+Inf"},"name":"n"}`,
+		},
+		{
+			name:     "pointer slice",
+			input:    &[]interface{}{math.Inf(-1)},
+			expected: `{"values":["-Inf"]}`,
+		},
+		{
+			name:     "generic map",
+			input:    map[string]interface{}{"nan": math.NaN()},
+			expected: `{"nan":"NaN"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry := &loggingpb.LogEntry{}
+			if err := setEntryPayload(entry, test.input); err != nil {
+				t.Fatal("Set error", err)
+			}
+			got := payloadJSON(t, entry.GetJsonPayload())
+			if got != test.expected {
+				t.Fatal("Unexpected payload", got)
+			}
+		})
+	}
+
+	entry := &loggingpb.LogEntry{}
+	if err := setEntryPayload(entry, math.NaN()); err != nil {
+		t.Fatal("Set error", err)
+	}
+	if entry.GetTextPayload() != "NaN" {
+		t.Fatal("Unexpected text payload", entry.Payload)
+	}
+}
+
+func TestCyclicPayload(t *testing.T) {
+	type node struct {
+		F    float64
+		Next *node
+	}
+	finite := &node{F: 1}
+	finite.Next = finite
+	nonFinite := &node{F: math.NaN()}
+	nonFinite.Next = nonFinite
+
+	tests := []struct {
+		name  string
+		input interface{}
+	}{
+		{name: "struct", input: finite},
+		{name: "struct with NaN", input: nonFinite},
+		{name: "struct in map", input: map[string]interface{}{"node": nonFinite}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry := &loggingpb.LogEntry{}
+			if err := setEntryPayload(entry, test.input); !errors.Is(err, ErrPayloadMarshal) {
+				t.Fatal("Unexpected error", err)
+			}
+		})
+	}
+
+	// The same value twice is not a cycle.
+	shared := &node{F: math.Inf(1)}
+	entry := &loggingpb.LogEntry{}
+	if err := setEntryPayload(entry, []*node{shared, shared}); err != nil {
+		t.Fatal("Set error", err)
+	}
+	if got := payloadJSON(t, entry.GetJsonPayload()); got != `{"values":[{"F":"+Inf","Next":null},{"F":"+Inf","Next":null}]}` {
+		t.Fatal("Unexpected payload", got)
+	}
+}
+
+type testMoney struct{ cents int64 }
+
+func (m *testMoney) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("$%d.%02d", m.cents/100, m.cents%100))
+}
+
+func TestStructFieldRules(t *testing.T) {
+	type order struct {
+		Total testMoney `json:"total"`
+		F     float64   `json:"f"`
+	}
+	type A struct{ X, Y int }
+	type B struct {
+		X int
+		Z int `json:"Y"`
+	}
+	type C struct{ Y float64 }
+	type conflict struct {
+		A
+		B
+		F float64 `json:"f"`
+	}
+	type hidden struct {
+		A
+		*C
+		Y float64 `json:"Y"`
+	}
+
+	tests := []struct {
+		name     string
+		input    interface{}
+		expected string
+	}{
+		{name: "pointer receiver", input: &order{Total: testMoney{150}, F: 1}, expected: `{"f":1,"total":"$1.50"}`},
+		{name: "pointer receiver with NaN", input: &order{Total: testMoney{150}, F: math.NaN()}, expected: `{"f":"NaN","total":"$1.50"}`},
+		{name: "embedded conflict", input: conflict{A: A{X: 1, Y: 2}, B: B{X: 3, Z: 4}, F: 1}, expected: `{"Y":4,"f":1}`},
+		{name: "embedded conflict with NaN", input: conflict{A: A{X: 1, Y: 2}, B: B{X: 3, Z: 4}, F: math.NaN()}, expected: `{"Y":4,"f":"NaN"}`},
+		{name: "outer field hides embedded", input: hidden{A: A{X: 1, Y: 2}, Y: math.Inf(1)}, expected: `{"X":1,"Y":"+Inf"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry := &loggingpb.LogEntry{}
+			if err := setEntryPayload(entry, test.input); err != nil {
+				t.Fatal("Set error", err)
+			}
+			if got := payloadJSON(t, entry.GetJsonPayload()); got != test.expected {
+				t.Fatal("Unexpected payload", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package cflog
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/api/option"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+)
+
+// Option configures a Client created by NewClient.
+type Option func(*Client)
+
+// WithLogName sets the log ID entries are written under, e.g. "my-service".
+// It defaults to the Cloud Functions log "cloudfunctions.googleapis.com/cloud-functions".
+func WithLogName(logID string) Option {
+	return func(c *Client) { c.logID = logID }
+}
+
+// WithProjectID sets the project used in the log name and resource labels.
+// It defaults to the GCP_PROJECT environment variable, then GOOGLE_CLOUD_PROJECT, and with
+// WithMetadataDetection to the project from the metadata server when neither is set.
+func WithProjectID(id string) Option {
+	return func(c *Client) { c.projectID = id }
+}
+
+// WithDefaultLabels sets labels added to every entry written by the client.
+// Labels passed to LogWithLabels override these on key collision.
+func WithDefaultLabels(labels map[string]string) Option {
+	return func(c *Client) { c.labels = mergeLabels(c.labels, labels) }
+}
+
+// WithTraceExtractor sets how the trace of an entry is found from the context passed to Log.
+// It defaults to TraceFromContext; use it to read spans from another tracing library,
+// or pass nil to never attach a trace.
+func WithTraceExtractor(f TraceExtractor) Option {
+	return func(c *Client) { c.traceExtractor = f }
+}
+
+// WithSourceLocation sets whether entries record the file, line, and function of the caller.
+// It is off by default because capturing the caller has a performance cost.
+func WithSourceLocation(enabled bool) Option {
+	return func(c *Client) { c.sourceLocation = enabled }
+}
+
+// WithStringers sets whether payloads implementing fmt.Stringer, or otherwise encoding.TextMarshaler,
+// are written as a text payload of their string form instead of being marshaled to JSON.
+// Types implementing json.Marshaler, errors, and protobuf messages are still written as JSON.
+// It is off by default since structs meant to be JSON sometimes have a String method too.
+func WithStringers(enabled bool) Option {
+	return func(c *Client) { c.stringers = enabled }
+}
+
+// WithInsertIDGenerator sets a function that produces the insertId of every entry.
+// Cloud Logging deduplicates entries sharing an insertId and timestamp, so the generator should only
+// repeat an ID for the same logical entry, e.g. when a function retries. Without it the insertId is left
+// empty and the API assigns one.
+func WithInsertIDGenerator(f func() string) Option {
+	return func(c *Client) { c.insertID = f }
+}
+
+// WithBatchSize buffers entries and writes them in a single request once n are pending.
+// Pending entries are written by Flush and Close, so call Flush before a function returns.
+func WithBatchSize(n int) Option {
+	return func(c *Client) { c.batchSize = n }
+}
+
+// WithFlushInterval buffers entries and writes them once the oldest pending entry is older than d.
+// A background goroutine also writes pending entries every d so they are not held while nothing is logged.
+// It stops when the client is closed or the context given to NewClient is done.
+// Pending entries are also written by Flush and Close.
+func WithFlushInterval(d time.Duration) Option {
+	return func(c *Client) { c.flushInterval = d }
+}
+
+// WithAsync makes Log queue entries for a background goroutine to write instead of waiting on the API.
+// Up to queueSize writes can be waiting, each the entries of one call or, with batching, one batch;
+// by default more are dropped and counted by Dropped, as are entries logged after Close.
+// Write errors can no longer be returned so they are passed to the handler set by SetErrorHandler.
+// Flush and Close wait for the queue to be written.
+func WithAsync(queueSize int) Option {
+	return func(c *Client) { c.asyncSize = queueSize }
+}
+
+// WithBlockWhenFull makes asynchronous logging wait for space in a full queue instead of dropping entries.
+func WithBlockWhenFull(block bool) Option {
+	return func(c *Client) { c.asyncBlock = block }
+}
+
+// WithOutput writes entries to w as lines of JSON instead of calling the logging API.
+// The lines use the structured format Cloud Logging ingests from stdout and stderr,
+// so it works locally and in runtimes that collect output.
+func WithOutput(w io.Writer) Option {
+	return func(c *Client) { c.localWriter = &jsonLineWriter{w: w} }
+}
+
+// WithConsole writes entries to w as lines of text for reading during local development instead of
+// calling the logging API. Severities are colored when w is a terminal unless NO_COLOR is set.
+func WithConsole(w io.Writer) Option {
+	return func(c *Client) { c.localWriter = newConsoleWriter(w) }
+}
+
+// WithDryRun writes each entry NewClient would send to the logging API to stderr as its LogEntry JSON
+// instead, to check payloads, severities, and labels without credentials.
+func WithDryRun(enabled bool) Option {
+	return func(c *Client) {
+		c.localWriter = nil
+		if enabled {
+			c.localWriter = &dryRunWriter{w: os.Stderr}
+		}
+	}
+}
+
+// WithDryRunOutput is WithDryRun writing to w instead of stderr.
+func WithDryRunOutput(w io.Writer) Option {
+	return func(c *Client) { c.localWriter = &dryRunWriter{w: w} }
+}
+
+// WithCloudFunctionResource attributes entries to the Cloud Function the code runs in,
+// for when DetectResource picks the wrong resource.
+func WithCloudFunctionResource() Option {
+	return func(c *Client) { c.resource = cloudFunctionResource }
+}
+
+// WithCloudRunResource attributes entries to the Cloud Run revision the code runs in instead of a Cloud Function.
+// The labels are read from the K_SERVICE, K_REVISION, and K_CONFIGURATION environment variables.
+func WithCloudRunResource() Option {
+	return func(c *Client) { c.resource = cloudRunResource }
+}
+
+// WithMonitoredResource attributes entries to the resource given, such as a gke_container or generic_task,
+// instead of one derived from the environment.
+// https://cloud.google.com/logging/docs/api/v2/resource-list
+func WithMonitoredResource(r *monitoredres.MonitoredResource) Option {
+	return func(c *Client) {
+		c.resource = func(string) *monitoredres.MonitoredResource { return r }
+	}
+}
+
+// WithResourceType attributes entries to a resource of type t with only its project_id label set, for
+// resource types without an option of their own. Set the rest of its labels with WithResourceLabels.
+// https://cloud.google.com/logging/docs/api/v2/resource-list
+func WithResourceType(t string) Option {
+	return func(c *Client) {
+		c.resource = func(projectID string) *monitoredres.MonitoredResource {
+			return &monitoredres.MonitoredResource{Type: t, Labels: map[string]string{"project_id": projectID}}
+		}
+	}
+}
+
+// WithResourceLabels sets labels of the monitored resource, keeping the rest of the labels of the resource
+// detected or chosen by another option, e.g. to set a region missing from the environment.
+func WithResourceLabels(labels map[string]string) Option {
+	return func(c *Client) { c.resourceLabels = mergeLabels(c.resourceLabels, labels) }
+}
+
+// WithMetadataDetection fills in a project ID and resource region, location, or zone missing from the
+// environment from the metadata server, for running on Compute Engine or GKE. The metadata server is
+// queried once per process with a short timeout, and nothing is filled in when it does not answer.
+func WithMetadataDetection() Option {
+	return func(c *Client) { c.metadataDetection = true }
+}
+
+// WithServiceContext sets the service name and version ReportError attributes errors to.
+func WithServiceContext(service, version string) Option {
+	return func(c *Client) {
+		c.serviceName = service
+		c.serviceVersion = version
+	}
+}
+
+// WithRepanic makes RecoverAndLog continue the panic after logging it instead of returning normally.
+func WithRepanic(repanic bool) Option {
+	return func(c *Client) { c.repanic = repanic }
+}
+
+// WithClock sets the function the client reads the current time from, such as when timestamping
+// buffered entries or timing requests. It defaults to time.Now and is for deterministic tests.
+func WithClock(now func() time.Time) Option {
+	return func(c *Client) { c.now = now }
+}
+
+// WithPartialSuccess has the logging API write the valid entries of a request even when others are
+// rejected, instead of failing the whole request. The rejected entries are returned as a *PartialWriteError.
+func WithPartialSuccess(enabled bool) Option {
+	return func(c *Client) { c.partialSuccess = enabled }
+}
+
+// WithHook notifies h of entries written, dropped, and failed.
+func WithHook(h Hook) Option {
+	return func(c *Client) { c.hook = h }
+}
+
+// WithRequestHook calls f with each write request after its entries are built, batched, and fitted,
+// right before it is sent, for inspecting or changing it, e.g. to set a label on every entry.
+// It is called once per request, not per retry, and from the background goroutine for async and
+// batched writes, so f must be safe to call concurrently.
+func WithRequestHook(f func(*loggingpb.WriteLogEntriesRequest)) Option {
+	return func(c *Client) { c.requestHook = f }
+}
+
+// WithMinSeverity makes the client skip entries below the severity given without calling the logging API.
+// Skipped entries return a nil error.
+func WithMinSeverity(s Severity) Option {
+	return func(c *Client) { c.minSeverity = s }
+}
+
+// WithSampler sets a function called with the severity of each entry that reports whether to keep it.
+// Dropped entries are skipped before their payload is converted and return a nil error.
+func WithSampler(keep func(Severity) bool) Option {
+	return func(c *Client) { c.sampler = keep }
+}
+
+// WithSampleRate keeps entries at or below the severity given with probability ratio, e.g.
+// WithSampleRate(SeverityDebug, 0.01) keeps about 1% of debug entries.
+// Entries above the severity, and errors or worse, are always kept.
+func WithSampleRate(upTo Severity, ratio float64) Option {
+	return WithSampler(func(s Severity) bool {
+		if s > upTo || s >= SeverityError {
+			return true
+		}
+		return rand.Float64() < ratio
+	})
+}
+
+// WithRetry retries writes that fail with Unavailable, ResourceExhausted, Aborted, or DeadlineExceeded
+// up to maxAttempts tries in total, waiting baseDelay after the first failure and doubling it after each
+// one after that. Other errors, like InvalidArgument or PermissionDenied, are returned immediately.
+func WithRetry(maxAttempts int, baseDelay time.Duration) Option {
+	return func(c *Client) {
+		c.retryAttempts = maxAttempts
+		c.retryDelay = baseDelay
+	}
+}
+
+// WithWriteTimeout bounds each write to the logging API by d so a slow API cannot stall the caller.
+// A write that times out is retried if WithRetry allows it, otherwise its error is returned.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(c *Client) { c.writeTimeout = d }
+}
+
+// WithRedactedFields replaces the value of JSON payload fields with these names by "***" before
+// entries are written, including fields of nested objects and arrays. Names match case-insensitively.
+func WithRedactedFields(names ...string) Option {
+	return func(c *Client) {
+		if c.redacted == nil {
+			c.redacted = make(map[string]bool, len(names))
+		}
+		for _, n := range names {
+			c.redacted[strings.ToLower(n)] = true
+		}
+	}
+}
+
+// WithMaxEntrySize limits entries to about bytes in size so an oversized entry does not fail the whole
+// request. Cloud Logging rejects entries over 256KB. A text payload over the limit is cut and ends with
+// "...(truncated)", and the largest fields of a JSON payload are dropped until the entry fits.
+// An entry that still does not fit returns an error wrapping ErrEntryTooLarge.
+func WithMaxEntrySize(bytes int) Option {
+	return func(c *Client) { c.maxEntrySize = bytes }
+}
+
+// WithStrictLabels sets whether an entry with a label key over 512 bytes or a value over 64KB returns an
+// error wrapping ErrLabelTooLong instead of being written with them cut to end with "...(truncated)".
+func WithStrictLabels(enabled bool) Option {
+	return func(c *Client) { c.strictLabels = enabled }
+}
+
+// WithEndpoint makes NewClient connect to the logging API at addr, such as a regional endpoint
+// or a fake server in integration tests.
+func WithEndpoint(addr string) Option {
+	return func(c *Client) { c.clientOptions = append(c.clientOptions, option.WithEndpoint(addr)) }
+}
+
+// WithClientOptions passes options to the logging client NewClient creates, such as credentials,
+// a user agent, or a custom endpoint. NewClient without them uses Application Default Credentials.
+func WithClientOptions(opts ...option.ClientOption) Option {
+	return func(c *Client) { c.clientOptions = append(c.clientOptions, opts...) }
+}
+
+// WithMessageKey sets the JSON payload field messages are written under, "message" by default.
+// It applies to LogFields, LogKV, LogError, error payloads, context fields added to a text payload,
+// and the slog, logr, and JSONWriter adapters. ReportError always uses "message" as Error Reporting requires.
+func WithMessageKey(key string) Option {
+	return func(c *Client) { c.messageKey = key }
+}
+
+// WithSpecialFields moves the special fields of a JSON payload onto the entry the way Cloud Logging does
+// for logs written to stdout, for payloads forwarded from services that already log that format.
+// A "severity" replaces the severity logged at, though the minimum severity and sampling still use
+// the one logged at, and "logging.googleapis.com/trace", "logging.googleapis.com/spanId", and
+// "logging.googleapis.com/trace_sampled" take precedence over a trace in the context.
+// A payload left with only a message becomes a text payload.
+// https://cloud.google.com/logging/docs/structured-logging#special-payload-fields
+func WithSpecialFields() Option {
+	return func(c *Client) { c.specialFields = true }
+}
@@ -0,0 +1,67 @@
+package cflog
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+	"google.golang.org/grpc/status"
+)
+
+// PartialWriteError is returned by a write with WithPartialSuccess when the logging API rejected
+// some of its entries and wrote the rest.
+type PartialWriteError struct {
+	// Rejected maps the index of each rejected entry among those written in the request to why it was rejected.
+	Rejected map[int]error
+	// Total is how many entries were in the request.
+	Total int
+}
+
+func (e *PartialWriteError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d of %d log entries rejected", len(e.Rejected), e.Total)
+	for _, i := range e.indexes() {
+		fmt.Fprintf(&b, "; entry %d: %v", i, e.Rejected[i])
+	}
+	return b.String()
+}
+
+// Unwrap returns the errors of the rejected entries in order so errors.Is and errors.As can match them.
+func (e *PartialWriteError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Rejected))
+	for _, i := range e.indexes() {
+		errs = append(errs, e.Rejected[i])
+	}
+	return errs
+}
+
+func (e *PartialWriteError) indexes() []int {
+	indexes := make([]int, 0, len(e.Rejected))
+	for i := range e.Rejected {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// partialWriteError converts the error of a request with partial success into a PartialWriteError
+// when it lists the entries the logging API rejected, and returns it unchanged otherwise.
+func partialWriteError(err error, total int) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	for _, d := range st.Details() {
+		partial, ok := d.(*loggingpb.WriteLogEntriesPartialErrors)
+		if !ok || len(partial.LogEntryErrors) == 0 {
+			continue
+		}
+		rejected := make(map[int]error, len(partial.LogEntryErrors))
+		for i, s := range partial.LogEntryErrors {
+			rejected[int(i)] = status.ErrorProto(s)
+		}
+		return &PartialWriteError{Rejected: rejected, Total: total}
+	}
+	return err
+}
@@ -0,0 +1,393 @@
+package cflog
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ErrPayloadMarshal is returned when a payload cannot be converted to JSON.
+// The error returned wraps both it and the marshal error.
+var ErrPayloadMarshal = errors.New("could not marshal payload")
+
+// arrayPayloadKey holds a JSON array payload since a jsonPayload must be an object.
+const arrayPayloadKey = "values"
+
+// Text is a payload always written as a text payload, skipping the check for whether it holds JSON.
+// Use it for strings that are known to be plain text, or that look like JSON but should stay text.
+type Text string
+
+// JSONPayload is a payload always written as a JSON payload. Create one with JSON.
+type JSONPayload struct {
+	v interface{}
+}
+
+// JSON returns a payload for v that is always written as a JSON payload. A string or []byte must hold a
+// JSON object or array, and any other value must marshal to one, or logging it returns an error wrapping
+// ErrPayloadMarshal instead of falling back to a text payload.
+func JSON(v interface{}) JSONPayload {
+	return JSONPayload{v: v}
+}
+
+// setEntryPayload sets a JSON payload when the input is or marshals to a JSON object or array,
+// and a text payload otherwise. Strings that are other JSON values, like numbers, stay text.
+func setEntryPayload(entry *loggingpb.LogEntry, in interface{}) error {
+	return setPayload(entry, in, defaultMessageKey)
+}
+
+// setPayload is setEntryPayload with the message of an error payload under messageKey.
+func setPayload(entry *loggingpb.LogEntry, in interface{}, messageKey string) error {
+	switch v := in.(type) {
+	case Text:
+		entry.Payload = &loggingpb.LogEntry_TextPayload{TextPayload: string(v)}
+		return nil
+	case JSONPayload:
+		return setJSONPayload(entry, v.v, messageKey)
+	}
+	if formatted, ok := formatValue(in); ok {
+		in = formatted
+	}
+
+	// Generic maps and slices convert directly to avoid a marshal and unmarshal round-trip.
+	// Anything they hold that cannot convert directly falls back to the JSON path below.
+	switch v := in.(type) {
+	case map[string]interface{}:
+		if payload, err := newStruct(v); err == nil {
+			entry.Payload = &loggingpb.LogEntry_JsonPayload{JsonPayload: payload}
+			return nil
+		}
+	case []interface{}:
+		if list, err := newList(v); err == nil {
+			entry.Payload = &loggingpb.LogEntry_JsonPayload{JsonPayload: arrayPayload(list)}
+			return nil
+		}
+	}
+
+	var s string
+	switch v := in.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	case protoadapt.MessageV1:
+		// Protobuf messages use their JSON mapping so field names match other proto based logs.
+		data, err := protojson.Marshal(protoadapt.MessageV2Of(v))
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrPayloadMarshal, err)
+		}
+		s = string(data)
+	case error:
+		// Errors rarely have exported fields so marshaling them would lose the message.
+		payload, err := newStruct(errorFields(v, messageKey))
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrPayloadMarshal, err)
+		}
+		entry.Payload = &loggingpb.LogEntry_JsonPayload{JsonPayload: payload}
+		return nil
+	default:
+		if v == nil {
+			break
+		}
+		data, err := json.Marshal(v)
+		var unsupported *json.UnsupportedValueError
+		if errors.As(err, &unsupported) && isNonFinite(unsupported) {
+			// NaN and infinite floats have no JSON form so convert directly and write them as strings.
+			if setValuePayload(entry, v) {
+				return nil
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrPayloadMarshal, err)
+		}
+		s = string(data)
+	}
+
+	isObject := strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}")
+	isArray := strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]")
+	if isObject || isArray {
+		if value, err := jsonValue(s); err == nil {
+			switch kind := value.Kind.(type) {
+			case *structpb.Value_StructValue:
+				entry.Payload = &loggingpb.LogEntry_JsonPayload{JsonPayload: kind.StructValue}
+				return nil
+			case *structpb.Value_ListValue:
+				entry.Payload = &loggingpb.LogEntry_JsonPayload{JsonPayload: arrayPayload(kind.ListValue)}
+				return nil
+			}
+		}
+	}
+
+	entry.Payload = &loggingpb.LogEntry_TextPayload{TextPayload: s}
+	return nil
+}
+
+// stringerPayload returns the string form of a payload implementing fmt.Stringer or encoding.TextMarshaler
+// as Text, and any other payload unchanged. Nil pointers are left for the JSON path to write as null.
+func stringerPayload(in interface{}) interface{} {
+	switch in.(type) {
+	case nil, string, []byte, Text, JSONPayload, error, protoadapt.MessageV1, json.Marshaler:
+		return in
+	}
+	if v := reflect.ValueOf(in); v.Kind() == reflect.Pointer && v.IsNil() {
+		return in
+	}
+	switch v := in.(type) {
+	case fmt.Stringer:
+		return Text(v.String())
+	case encoding.TextMarshaler:
+		if text, err := v.MarshalText(); err == nil {
+			return Text(text)
+		}
+	}
+	return in
+}
+
+// setJSONPayload sets a JSON payload from v, returning an error if it is not a JSON object or array.
+func setJSONPayload(entry *loggingpb.LogEntry, v interface{}, messageKey string) error {
+	switch raw := v.(type) {
+	case string:
+		v = json.RawMessage(raw)
+	case []byte:
+		v = json.RawMessage(raw)
+	}
+	if raw, ok := v.(json.RawMessage); ok {
+		value, err := jsonValue(string(raw))
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrPayloadMarshal, err)
+		}
+		switch kind := value.Kind.(type) {
+		case *structpb.Value_StructValue:
+			entry.Payload = &loggingpb.LogEntry_JsonPayload{JsonPayload: kind.StructValue}
+			return nil
+		case *structpb.Value_ListValue:
+			entry.Payload = &loggingpb.LogEntry_JsonPayload{JsonPayload: arrayPayload(kind.ListValue)}
+			return nil
+		}
+	} else if err := setPayload(entry, v, messageKey); err != nil {
+		return err
+	}
+	if entry.GetJsonPayload() == nil {
+		return fmt.Errorf("%w: %T is not a JSON object or array", ErrPayloadMarshal, v)
+	}
+	return nil
+}
+
+// setValuePayload sets the payload from a direct conversion of v, reporting whether it could convert it.
+func setValuePayload(entry *loggingpb.LogEntry, v interface{}) bool {
+	generic, err := genericValue(reflect.ValueOf(v), map[visit]bool{})
+	if err != nil {
+		return false
+	}
+	value, err := newValue(generic)
+	if err != nil {
+		return false
+	}
+	switch kind := value.Kind.(type) {
+	case *structpb.Value_StructValue:
+		entry.Payload = &loggingpb.LogEntry_JsonPayload{JsonPayload: kind.StructValue}
+	case *structpb.Value_ListValue:
+		entry.Payload = &loggingpb.LogEntry_JsonPayload{JsonPayload: arrayPayload(kind.ListValue)}
+	case *structpb.Value_StringValue:
+		entry.Payload = &loggingpb.LogEntry_TextPayload{TextPayload: kind.StringValue}
+	default:
+		return false
+	}
+	return true
+}
+
+// arrayPayload wraps a list in an object under arrayPayloadKey.
+func arrayPayload(list *structpb.ListValue) *structpb.Struct {
+	return &structpb.Struct{Fields: map[string]*structpb.Value{
+		arrayPayloadKey: {Kind: &structpb.Value_ListValue{ListValue: list}},
+	}}
+}
+
+// newStruct converts a map into a Struct the same way marshaling it to JSON and back would.
+func newStruct(m map[string]interface{}) (*structpb.Struct, error) {
+	fields := make(map[string]*structpb.Value, len(m))
+	for k, v := range m {
+		value, err := newValue(v)
+		if err != nil {
+			return nil, err
+		}
+		fields[k] = value
+	}
+	return &structpb.Struct{Fields: fields}, nil
+}
+
+// newList converts a slice into a ListValue the same way marshaling it to JSON and back would.
+func newList(l []interface{}) (*structpb.ListValue, error) {
+	values := make([]*structpb.Value, len(l))
+	for i, v := range l {
+		value, err := newValue(v)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return &structpb.ListValue{Values: values}, nil
+}
+
+// newValue converts the types a generic map or slice usually holds into a Value.
+// Any other type is converted with json.Marshal.
+func newValue(v interface{}) (*structpb.Value, error) {
+	if formatted, ok := formatValue(v); ok {
+		v = formatted
+	}
+	switch v := v.(type) {
+	case nil:
+		return &structpb.Value{Kind: &structpb.Value_NullValue{}}, nil
+	case bool:
+		return &structpb.Value{Kind: &structpb.Value_BoolValue{BoolValue: v}}, nil
+	case string:
+		return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: v}}, nil
+	case []byte:
+		return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: base64.StdEncoding.EncodeToString(v)}}, nil
+	case json.RawMessage:
+		// Raw JSON is embedded as structured JSON rather than as its bytes.
+		return jsonValue(string(v))
+	case json.Number:
+		return numberLiteral(v)
+	case *big.Int:
+		if v == nil {
+			return &structpb.Value{Kind: &structpb.Value_NullValue{}}, nil
+		}
+		return bigIntValue(v), nil
+	case big.Int:
+		// encoding/json writes a big.Int that is not addressable as an empty object.
+		return bigIntValue(&v), nil
+	case int:
+		return intValue(int64(v)), nil
+	case int8:
+		return numberValue(float64(v)), nil
+	case int16:
+		return numberValue(float64(v)), nil
+	case int32:
+		return numberValue(float64(v)), nil
+	case int64:
+		return intValue(v), nil
+	case uint:
+		return uintValue(uint64(v)), nil
+	case uint8:
+		return numberValue(float64(v)), nil
+	case uint16:
+		return numberValue(float64(v)), nil
+	case uint32:
+		return numberValue(float64(v)), nil
+	case uint64:
+		return uintValue(v), nil
+	case float32:
+		// Use the shortest decimal form of the float32 like encoding/json rather than widening its bits.
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(v), 'g', -1, 32), 64)
+		return numberValue(f), nil
+	case float64:
+		return numberValue(v), nil
+	case map[string]interface{}:
+		s, err := newStruct(v)
+		if err != nil {
+			return nil, err
+		}
+		return &structpb.Value{Kind: &structpb.Value_StructValue{StructValue: s}}, nil
+	case []interface{}:
+		l, err := newList(v)
+		if err != nil {
+			return nil, err
+		}
+		return &structpb.Value{Kind: &structpb.Value_ListValue{ListValue: l}}, nil
+	}
+	// Anything else, such as a struct in a map, is marshaled on its own so the values around it are still formatted.
+	data, err := json.Marshal(v)
+	var unsupported *json.UnsupportedValueError
+	if errors.As(err, &unsupported) && isNonFinite(unsupported) {
+		generic, gErr := genericValue(reflect.ValueOf(v), map[visit]bool{})
+		if gErr == nil {
+			return newValue(generic)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return jsonValue(string(data))
+}
+
+// numberValue converts a float into a Value, using a string for NaN and infinite floats.
+func numberValue(f float64) *structpb.Value {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: nonFiniteString(f)}}
+	}
+	return &structpb.Value{Kind: &structpb.Value_NumberValue{NumberValue: f}}
+}
+
+// maxExactInt is the largest magnitude up to which a float64 holds every integer exactly.
+// Struct numbers are float64 so integers beyond it are kept as strings instead of being rounded.
+const maxExactInt = 1 << 53
+
+// intValue converts an integer into a Value, using a string if a float64 cannot hold it exactly.
+func intValue(i int64) *structpb.Value {
+	if i > maxExactInt || i < -maxExactInt {
+		return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: strconv.FormatInt(i, 10)}}
+	}
+	return numberValue(float64(i))
+}
+
+// uintValue converts an unsigned integer into a Value, using a string if a float64 cannot hold it exactly.
+func uintValue(u uint64) *structpb.Value {
+	if u > maxExactInt {
+		return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: strconv.FormatUint(u, 10)}}
+	}
+	return numberValue(float64(u))
+}
+
+// bigIntValue converts a big integer into a Value, using a string if a float64 cannot hold it exactly.
+func bigIntValue(i *big.Int) *structpb.Value {
+	if i.IsInt64() {
+		return intValue(i.Int64())
+	}
+	return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: i.String()}}
+}
+
+// numberLiteral converts a JSON number into a Value, keeping integers too large for a float64 as strings.
+func numberLiteral(n json.Number) (*structpb.Value, error) {
+	f, err := n.Float64()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.ContainsAny(n.String(), ".eE") {
+		if i, err := n.Int64(); err == nil {
+			return intValue(i), nil
+		}
+		if u, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+			return uintValue(u), nil
+		}
+		// Integers beyond 64 bits cannot be held exactly either.
+		return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: n.String()}}, nil
+	}
+	return numberValue(f), nil
+}
+
+// jsonValue parses JSON into a Value with numbers converted by numberLiteral so large integers keep their precision.
+func jsonValue(s string) (*structpb.Value, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return newValue(v)
+}
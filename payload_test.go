@@ -0,0 +1,358 @@
+package cflog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/logging/type"
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestSetEntrypayload(t *testing.T) {
+	textType := "*logging.LogEntry_TextPayload"
+	jsonType := "*logging.LogEntry_JsonPayload"
+
+	tests := []struct {
+		name         string
+		input        interface{}
+		expectedType string
+	}{
+		{name: "string", input: "str", expectedType: textType},
+		{name: "empty string", input: "", expectedType: textType},
+		{name: "[]byte", input: []byte("bytes"), expectedType: textType},
+		{name: "nil", input: nil, expectedType: textType},
+		{name: "struct", input: struct{ M string }{M: "in"}, expectedType: jsonType},
+		{name: "JSON string", input: `{"m": "m"}`, expectedType: jsonType},
+		{name: "bad JSON string", input: `{m": "m"}`, expectedType: textType},
+		{name: "empty struct", input: struct{}{}, expectedType: jsonType},
+		{name: "uninitialized struct", input: struct{ M string }{}, expectedType: jsonType},
+		{name: "JSON array string", input: `["a", "b"]`, expectedType: jsonType},
+		{name: "bad JSON array string", input: `["a", b]`, expectedType: textType},
+		{name: "number string", input: "42", expectedType: textType},
+		{name: "slice", input: []string{"a"}, expectedType: jsonType},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry := &loggingpb.LogEntry{}
+			if err := setEntryPayload(entry, test.input); err != nil {
+				t.Fatal("Set error", err)
+			}
+
+			pType := fmt.Sprintf("%T", entry.Payload)
+			if pType != test.expectedType {
+				t.Fatal("Unexpected type", pType)
+			}
+		})
+	}
+}
+
+func TestArrayPayload(t *testing.T) {
+	entry := &loggingpb.LogEntry{}
+	if err := setEntryPayload(entry, `["a", 1]`); err != nil {
+		t.Fatal("Set error", err)
+	}
+	values := entry.GetJsonPayload().Fields[arrayPayloadKey].GetListValue().GetValues()
+	if len(values) != 2 || values[0].GetStringValue() != "a" || values[1].GetNumberValue() != 1 {
+		t.Fatal("Unexpected values", values)
+	}
+}
+
+func TestGenericPayloadMatchesJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input interface{}
+	}{
+		{name: "map", input: map[string]interface{}{
+			"s": "str", "i": 42, "f": 1.5, "b": true, "n": nil, "bytes": []byte("hi"),
+			"nested": map[string]interface{}{"l": []interface{}{"a", 1}},
+		}},
+		{name: "map of struct", input: map[string]interface{}{"s": struct{ M string }{M: "m"}}},
+		{name: "slice", input: []interface{}{"a", 1, map[string]interface{}{"k": "v"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			direct := &loggingpb.LogEntry{}
+			if err := setEntryPayload(direct, test.input); err != nil {
+				t.Fatal("Set error", err)
+			}
+
+			data, err := json.Marshal(test.input)
+			if err != nil {
+				t.Fatal("Marshal error", err)
+			}
+			roundTrip := &loggingpb.LogEntry{}
+			if err := setEntryPayload(roundTrip, string(data)); err != nil {
+				t.Fatal("Set error", err)
+			}
+
+			if !proto.Equal(protoadapt.MessageV2Of(direct), protoadapt.MessageV2Of(roundTrip)) {
+				t.Fatal("Payloads differ", direct, roundTrip)
+			}
+		})
+	}
+}
+
+func TestRawMessagePayload(t *testing.T) {
+	raw := json.RawMessage(`{"id": 1, "tags": ["a"]}`)
+	tests := []struct {
+		name  string
+		input interface{}
+	}{
+		{name: "struct", input: struct {
+			Raw json.RawMessage `json:"raw"`
+		}{Raw: raw}},
+		{name: "struct pointer field", input: struct {
+			Raw *json.RawMessage `json:"raw"`
+		}{Raw: &raw}},
+		{name: "map", input: map[string]interface{}{"raw": raw}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry := &loggingpb.LogEntry{}
+			if err := setEntryPayload(entry, test.input); err != nil {
+				t.Fatal("Set error", err)
+			}
+			fields := entry.GetJsonPayload().GetFields()["raw"].GetStructValue().GetFields()
+			if fields["id"].GetNumberValue() != 1 || fields["tags"].GetListValue() == nil {
+				t.Fatal("Expected raw JSON to be structured", entry.GetJsonPayload())
+			}
+		})
+	}
+}
+
+func TestPayloadMarshalError(t *testing.T) {
+	err := setEntryPayload(&loggingpb.LogEntry{}, struct{ C chan int }{})
+	if !errors.Is(err, ErrPayloadMarshal) {
+		t.Fatal("Unexpected error", err)
+	}
+	var typeErr *json.UnsupportedTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatal("Expected the marshal error to be wrapped", err)
+	}
+}
+
+func TestProtoMessagePayload(t *testing.T) {
+	req := &ltype.HttpRequest{RequestMethod: "GET", Status: 200, Latency: durationpb.New(1500 * time.Millisecond)}
+	entry := &loggingpb.LogEntry{}
+	if err := setEntryPayload(entry, req); err != nil {
+		t.Fatal("Set error", err)
+	}
+	got := payloadJSON(t, entry.GetJsonPayload())
+	if expected := `{"latency":"1.500s","requestMethod":"GET","status":200}`; got != expected {
+		t.Fatal("Unexpected payload", got)
+	}
+}
+
+// payloadJSON marshals a payload with sorted keys so it can be compared to a string.
+func payloadJSON(t *testing.T, s *structpb.Struct) string {
+	t.Helper()
+	data, err := json.Marshal(s.AsMap())
+	if err != nil {
+		t.Fatal("Marshal error", err)
+	}
+	return string(data)
+}
+
+func TestTextPayload(t *testing.T) {
+	for _, s := range []string{`{"a":1}`, `[1]`, "plain"} {
+		entry := &loggingpb.LogEntry{}
+		if err := setEntryPayload(entry, Text(s)); err != nil {
+			t.Fatal("Set error", err)
+		}
+		if entry.GetTextPayload() != s {
+			t.Fatal("Unexpected payload", entry.Payload)
+		}
+	}
+}
+
+func TestJSONPayload(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    interface{}
+		expected string
+		err      bool
+	}{
+		{name: "string object", input: `{"id":9007199254740993}`, expected: `{"id":"9007199254740993"}`},
+		{name: "bytes array", input: []byte(`[1,2]`), expected: `{"values":[1,2]}`},
+		{name: "raw message", input: json.RawMessage(`{"a":true}`), expected: `{"a":true}`},
+		{name: "map", input: map[string]interface{}{"a": 1}, expected: `{"a":1}`},
+		{name: "struct", input: struct {
+			A int `json:"a"`
+		}{A: 1}, expected: `{"a":1}`},
+		{name: "plain string", input: "plain", err: true},
+		{name: "JSON scalar", input: "5", err: true},
+		{name: "number", input: 5, err: true},
+		{name: "nil", input: nil, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry := &loggingpb.LogEntry{}
+			err := setEntryPayload(entry, JSON(test.input))
+			if test.err {
+				if !errors.Is(err, ErrPayloadMarshal) {
+					t.Fatal("Unexpected error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("Set error", err)
+			}
+			if got := payloadJSON(t, entry.GetJsonPayload()); got != test.expected {
+				t.Fatal("Unexpected payload", got)
+			}
+		})
+	}
+}
+
+type testColor int
+
+func (c testColor) String() string { return [...]string{"red", "green"}[c] }
+
+type testVersion struct{ Major, Minor int }
+
+func (v testVersion) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("v%d.%d", v.Major, v.Minor)), nil
+}
+
+type testMarshaledStringer struct{ Name string }
+
+func (s testMarshaledStringer) String() string               { return "stringer" }
+func (s testMarshaledStringer) MarshalJSON() ([]byte, error) { return []byte(`{"name":"json"}`), nil }
+
+func TestWithStringers(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload interface{}
+		text    string
+		json    string
+	}{
+		{name: "stringer", payload: testColor(1), text: "green"},
+		{name: "pointer stringer", payload: func() *testColor { c := testColor(0); return &c }(), text: "red"},
+		{name: "text marshaler", payload: testVersion{Major: 1, Minor: 2}, text: "v1.2"},
+		{name: "JSON marshaler", payload: testMarshaledStringer{}, json: `{"name":"json"}`},
+		{name: "error", payload: errors.New("boom"), json: `{"message":"boom"}`},
+		{name: "plain struct", payload: struct {
+			A int `json:"a"`
+		}{A: 1}, json: `{"a":1}`},
+	}
+
+	c := newClient(WithStringers(true))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry, err := c.BuildEntry(context.Background(), SeverityInfo, test.payload)
+			if err != nil {
+				t.Fatal("Build error", err)
+			}
+			if test.json != "" {
+				if got := payloadJSON(t, entry.GetJsonPayload()); got != test.json {
+					t.Fatal("Unexpected payload", got)
+				}
+				return
+			}
+			if entry.GetTextPayload() != test.text {
+				t.Fatal("Unexpected payload", entry.Payload)
+			}
+		})
+	}
+
+	entry, err := newClient().BuildEntry(context.Background(), SeverityInfo, testVersion{Major: 1, Minor: 2})
+	if err != nil {
+		t.Fatal("Build error", err)
+	}
+	if entry.GetTextPayload() != `"v1.2"` {
+		t.Fatal("Unexpected payload", entry.Payload)
+	}
+	entry, err = newClient().BuildEntry(context.Background(), SeverityInfo, testColor(1))
+	if err != nil {
+		t.Fatal("Build error", err)
+	}
+	if entry.GetTextPayload() != "1" {
+		t.Fatal("Unexpected payload", entry.Payload)
+	}
+}
+
+func TestNumberTypesPayload(t *testing.T) {
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	tests := []struct {
+		name     string
+		input    interface{}
+		expected string
+	}{
+		{name: "json number", input: map[string]interface{}{"n": json.Number("12.5")}, expected: `{"n":12.5}`},
+		{name: "large json number", input: map[string]interface{}{"n": json.Number("123456789012345678901234567890")}, expected: `{"n":"123456789012345678901234567890"}`},
+		{name: "big int", input: map[string]interface{}{"n": big.NewInt(42)}, expected: `{"n":42}`},
+		{name: "large big int", input: map[string]interface{}{"n": huge}, expected: `{"n":"123456789012345678901234567890"}`},
+		{name: "big int value", input: map[string]interface{}{"n": *big.NewInt(-7)}, expected: `{"n":-7}`},
+		{name: "nil big int", input: map[string]interface{}{"n": (*big.Int)(nil)}, expected: `{"n":null}`},
+		{name: "struct", input: struct {
+			N *big.Int    `json:"n"`
+			J json.Number `json:"j"`
+		}{N: huge, J: "3"}, expected: `{"j":3,"n":"123456789012345678901234567890"}`},
+		{name: "struct with NaN", input: struct {
+			N big.Int     `json:"n"`
+			J json.Number `json:"j"`
+			F float64     `json:"f"`
+		}{N: *big.NewInt(5), J: "3", F: math.NaN()}, expected: `{"f":"NaN","j":3,"n":5}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry := &loggingpb.LogEntry{}
+			if err := setEntryPayload(entry, test.input); err != nil {
+				t.Fatal("Set error", err)
+			}
+			if got := payloadJSON(t, entry.GetJsonPayload()); got != test.expected {
+				t.Fatal("Unexpected payload", got)
+			}
+		})
+	}
+}
+
+func TestLargeIntegerPayload(t *testing.T) {
+	const id int64 = 9007199254740993
+	tests := []struct {
+		name  string
+		input interface{}
+	}{
+		{name: "struct", input: struct {
+			ID int64 `json:"id"`
+		}{ID: id}},
+		{name: "map", input: map[string]interface{}{"id": id}},
+		{name: "JSON string", input: `{"id": 9007199254740993}`},
+		{name: "number", input: map[string]interface{}{"id": json.Number("9007199254740993")}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry := &loggingpb.LogEntry{}
+			if err := setEntryPayload(entry, test.input); err != nil {
+				t.Fatal("Set error", err)
+			}
+			if got := entry.GetJsonPayload().Fields["id"].GetStringValue(); got != "9007199254740993" {
+				t.Fatal("Unexpected id", entry.GetJsonPayload())
+			}
+		})
+	}
+
+	entry := &loggingpb.LogEntry{}
+	if err := setEntryPayload(entry, map[string]interface{}{"small": 42}); err != nil {
+		t.Fatal("Set error", err)
+	}
+	if got := entry.GetJsonPayload().Fields["small"].GetNumberValue(); got != 42 {
+		t.Fatal("Unexpected small number", entry.GetJsonPayload())
+	}
+}
@@ -0,0 +1,39 @@
+package cflog
+
+import (
+	"strings"
+
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// redactedValue replaces the value of a redacted field.
+const redactedValue = "***"
+
+// redactEntry masks the fields of a JSON payload named in redacted, searching nested objects and arrays.
+func redactEntry(entry *loggingpb.LogEntry, redacted map[string]bool) {
+	if p, ok := entry.Payload.(*loggingpb.LogEntry_JsonPayload); ok {
+		redactStruct(p.JsonPayload, redacted)
+	}
+}
+
+func redactStruct(s *structpb.Struct, redacted map[string]bool) {
+	for k, v := range s.GetFields() {
+		if redacted[strings.ToLower(k)] {
+			s.Fields[k] = &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: redactedValue}}
+			continue
+		}
+		redactValue(v, redacted)
+	}
+}
+
+func redactValue(v *structpb.Value, redacted map[string]bool) {
+	switch kind := v.GetKind().(type) {
+	case *structpb.Value_StructValue:
+		redactStruct(kind.StructValue, redacted)
+	case *structpb.Value_ListValue:
+		for _, item := range kind.ListValue.GetValues() {
+			redactValue(item, redacted)
+		}
+	}
+}
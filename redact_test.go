@@ -0,0 +1,26 @@
+package cflog
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRedactedFields(t *testing.T) {
+	c, _ := newFakeClient(WithRedactedFields("email", "Token"))
+	ctx := WithFields(context.Background(), map[string]interface{}{"token": "t"})
+	payload := map[string]interface{}{
+		"Email": "a@b.c",
+		"user":  map[string]interface{}{"email": "d@e.f", "name": "n"},
+		"list":  []interface{}{map[string]interface{}{"email": "g@h.i"}},
+	}
+
+	entry, err := c.BuildEntry(ctx, SeverityInfo, payload)
+	if err != nil {
+		t.Fatal("Build error", err)
+	}
+	got := payloadJSON(t, entry.GetJsonPayload())
+	expected := `{"Email":"***","list":[{"email":"***"}],"token":"***","user":{"email":"***","name":"n"}}`
+	if got != expected {
+		t.Fatal("Unexpected payload", got)
+	}
+}
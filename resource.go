@@ -0,0 +1,228 @@
+package cflog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+)
+
+// ErrInvalidResource is wrapped by the error Validate returns when the resource is missing required labels.
+var ErrInvalidResource = errors.New("invalid monitored resource")
+
+// requiredResourceLabels are the labels entries need for the Logs Explorer to attribute them to a resource.
+var requiredResourceLabels = map[string][]string{
+	"cloud_function":     {"function_name", "project_id", "region"},
+	"cloud_run_revision": {"project_id", "revision_name", "service_name"},
+	"gce_instance":       {"instance_id", "project_id", "zone"},
+	"global":             {"project_id"},
+}
+
+// Validate returns an error wrapping ErrInvalidResource when the client has no project or its monitored
+// resource is missing labels its type requires, such as the region of a Cloud Function when FUNCTION_REGION
+// is not set. Without it such entries are still written but can end up attributed to the wrong resource.
+// Resource types it does not know only need a type.
+func (c Client) Validate() error {
+	r := c.logMonitoredResource
+	if r.GetType() == "" {
+		return fmt.Errorf("%w: no resource type", ErrInvalidResource)
+	}
+	if c.projectID == "" {
+		return fmt.Errorf("%w: no project ID", ErrInvalidResource)
+	}
+
+	var missing []string
+	for _, l := range requiredResourceLabels[r.Type] {
+		if r.Labels[l] == "" {
+			missing = append(missing, l)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%w: %s is missing labels %s", ErrInvalidResource, r.Type, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// resourceFunc builds the monitored resource entries are attributed to.
+type resourceFunc func(projectID string) *monitoredres.MonitoredResource
+
+// DetectResource returns the monitored resource of the Google Cloud product the code runs on.
+// It checks for Cloud Functions, then Cloud Run, then Compute Engine through the metadata server
+// until ctx is done, and falls back to the global resource. NewClient uses it unless a resource
+// option is given, waiting up to 2s for the metadata server. Clients created without connecting,
+// such as with NewClientWithWriter or WithOutput, only check the environment.
+func DetectResource(ctx context.Context) *monitoredres.MonitoredResource {
+	projectID := firstEnv("GCP_PROJECT", "GOOGLE_CLOUD_PROJECT")
+	if r := envResource(projectID); r != nil {
+		return r
+	}
+	if r := gceResource(ctx, projectID); r != nil {
+		return r
+	}
+	return globalResource(projectID)
+}
+
+// envResource returns the resource the environment variables show the code runs on, or nil if they show none.
+func envResource(projectID string) *monitoredres.MonitoredResource {
+	switch {
+	case os.Getenv("FUNCTION_NAME") != "" || os.Getenv("FUNCTION_TARGET") != "":
+		return cloudFunctionResource(projectID)
+	case os.Getenv("K_SERVICE") != "" && os.Getenv("K_REVISION") != "":
+		return cloudRunResource(projectID)
+	}
+	return nil
+}
+
+// globalResource is the resource of entries not attributed to a product.
+func globalResource(projectID string) *monitoredres.MonitoredResource {
+	return &monitoredres.MonitoredResource{
+		Type:   "global",
+		Labels: map[string]string{"project_id": projectID},
+	}
+}
+
+// gceLookup finds the Compute Engine resource for NewClient. Tests replace it to avoid the metadata server.
+var gceLookup = gceResource
+
+// gceResource returns the resource of the Compute Engine instance the code runs on,
+// or nil when not on Compute Engine or ctx is done first.
+func gceResource(ctx context.Context, projectID string) *monitoredres.MonitoredResource {
+	found := make(chan *monitoredres.MonitoredResource, 1)
+	go func() {
+		if !metadata.OnGCE() {
+			found <- nil
+			return
+		}
+		id, _ := metadata.InstanceID()
+		zone, _ := metadata.Zone()
+		if projectID == "" {
+			projectID, _ = metadata.ProjectID()
+		}
+		found <- &monitoredres.MonitoredResource{
+			Type: "gce_instance",
+			Labels: map[string]string{
+				"instance_id": id,
+				"project_id":  projectID,
+				"zone":        zone,
+			},
+		}
+	}()
+
+	select {
+	case r := <-found:
+		return r
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// cloudFunctionResource is the resource of a Cloud Function.
+// https://cloud.google.com/functions/docs/env-var
+func cloudFunctionResource(projectID string) *monitoredres.MonitoredResource {
+	return &monitoredres.MonitoredResource{
+		Type: "cloud_function",
+		Labels: map[string]string{
+			"function_name": firstEnv("FUNCTION_NAME", "K_SERVICE"),
+			"project_id":    projectID,
+			"region":        os.Getenv("FUNCTION_REGION"),
+		},
+	}
+}
+
+// cloudRunResource is the resource of a Cloud Run revision.
+// Cloud Run does not expose its region as an environment variable so location is left empty.
+// https://cloud.google.com/run/docs/container-contract#env-vars
+func cloudRunResource(projectID string) *monitoredres.MonitoredResource {
+	return &monitoredres.MonitoredResource{
+		Type: "cloud_run_revision",
+		Labels: map[string]string{
+			"configuration_name": os.Getenv("K_CONFIGURATION"),
+			"location":           "",
+			"project_id":         projectID,
+			"revision_name":      os.Getenv("K_REVISION"),
+			"service_name":       os.Getenv("K_SERVICE"),
+		},
+	}
+}
+
+// metadataTimeout bounds how long NewClient and WithMetadataDetection wait for the metadata server.
+const metadataTimeout = 2 * time.Second
+
+// metadataInfo is what WithMetadataDetection reads from the metadata server.
+type metadataInfo struct {
+	projectID string
+	zone      string
+}
+
+// metadataLookup returns the metadata server's project and zone, and is replaced in tests.
+var metadataLookup = cachedMetadata
+
+var (
+	metadataOnce      sync.Once
+	metadataInfoCache metadataInfo
+)
+
+// cachedMetadata queries the metadata server on first use and returns the same answer after that.
+// It returns empty values when not on Google Cloud or the server does not answer within metadataTimeout.
+func cachedMetadata() metadataInfo {
+	metadataOnce.Do(func() {
+		found := make(chan metadataInfo, 1)
+		go func() {
+			var info metadataInfo
+			if metadata.OnGCE() {
+				info.projectID, _ = metadata.ProjectID()
+				info.zone, _ = metadata.Zone()
+			}
+			found <- info
+		}()
+
+		select {
+		case metadataInfoCache = <-found:
+		case <-time.After(metadataTimeout):
+		}
+	})
+	return metadataInfoCache
+}
+
+// metadataResourceLabels returns values from the metadata server for the region, location, and zone
+// labels of the resource that are empty, querying it only when there are any.
+func metadataResourceLabels(r *monitoredres.MonitoredResource) map[string]string {
+	var empty []string
+	for _, l := range []string{"region", "location", "zone"} {
+		if v, ok := r.GetLabels()[l]; ok && v == "" {
+			empty = append(empty, l)
+		}
+	}
+	if len(empty) == 0 {
+		return nil
+	}
+
+	zone := metadataLookup().zone
+	if zone == "" {
+		return nil
+	}
+	labels := map[string]string{}
+	for _, l := range empty {
+		labels[l] = regionFromZone(zone)
+		if l == "zone" {
+			labels[l] = zone
+		}
+	}
+	return labels
+}
+
+// regionFromZone returns the region of a zone such as "us-central1" for "us-central1-a".
+func regionFromZone(zone string) string {
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		return zone[:i]
+	}
+	return zone
+}
@@ -0,0 +1,184 @@
+package cflog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"testing"
+	"time"
+
+	"google.golang.org/api/option"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+	"google.golang.org/grpc"
+)
+
+func TestCloudRunResource(t *testing.T) {
+	t.Setenv("K_SERVICE", "service")
+	t.Setenv("K_REVISION", "service-001")
+	t.Setenv("K_CONFIGURATION", "service")
+
+	r := newClient(WithProjectID("p"), WithCloudRunResource()).logMonitoredResource
+	if r.Type != "cloud_run_revision" {
+		t.Fatal("Unexpected type", r.Type)
+	}
+	if r.Labels["service_name"] != "service" || r.Labels["revision_name"] != "service-001" || r.Labels["project_id"] != "p" {
+		t.Fatal("Unexpected labels", r.Labels)
+	}
+}
+
+func TestWithMonitoredResource(t *testing.T) {
+	want := &monitoredres.MonitoredResource{Type: "generic_task", Labels: map[string]string{"job": "j"}}
+	if r := newClient(WithMonitoredResource(want)).logMonitoredResource; r != want {
+		t.Fatal("Unexpected resource", r)
+	}
+}
+
+func TestDetectResource(t *testing.T) {
+	for _, k := range []string{"FUNCTION_NAME", "FUNCTION_TARGET", "K_SERVICE", "K_REVISION"} {
+		t.Setenv(k, "")
+	}
+	t.Setenv("GCP_PROJECT", "p")
+
+	// Outside of Google Cloud the metadata server check fails quickly.
+	if r := DetectResource(context.Background()); r.Type != "global" && r.Type != "gce_instance" {
+		t.Fatal("Unexpected fallback type", r.Type)
+	}
+
+	t.Setenv("K_SERVICE", "service")
+	t.Setenv("K_REVISION", "service-001")
+	if r := DetectResource(context.Background()); r.Type != "cloud_run_revision" {
+		t.Fatal("Expected Cloud Run", r.Type)
+	}
+
+	t.Setenv("FUNCTION_TARGET", "Handler")
+	r := DetectResource(context.Background())
+	if r.Type != "cloud_function" || r.Labels["function_name"] != "service" || r.Labels["project_id"] != "p" {
+		t.Fatal("Expected Cloud Function", r)
+	}
+}
+
+func TestNewClientGCEDetection(t *testing.T) {
+	for _, k := range []string{"FUNCTION_NAME", "FUNCTION_TARGET", "K_SERVICE", "K_REVISION"} {
+		t.Setenv(k, "")
+	}
+	var lookups int
+	var deadline time.Time
+	gceLookup = func(ctx context.Context, projectID string) *monitoredres.MonitoredResource {
+		lookups++
+		deadline, _ = ctx.Deadline()
+		return &monitoredres.MonitoredResource{Type: "gce_instance", Labels: map[string]string{"project_id": projectID}}
+	}
+	defer func() { gceLookup = gceResource }()
+
+	c, _ := newFakeClient(WithProjectID("p"))
+	if lookups != 0 || c.logMonitoredResource.Type != "global" {
+		t.Fatal("Unexpected writer client detection", lookups, c.logMonitoredResource)
+	}
+	ctx := context.Background()
+	if _, err := NewClient(ctx, WithOutput(io.Discard)); err != nil || lookups != 0 {
+		t.Fatal("Unexpected local client detection", lookups, err)
+	}
+
+	c, err := NewClient(ctx, WithProjectID("p"), WithResourceLabels(map[string]string{"zone": "z"}),
+		WithClientOptions(option.WithoutAuthentication(), option.WithGRPCDialOption(grpc.WithInsecure())))
+	if err != nil {
+		t.Fatal("NewClient error", err)
+	}
+	defer c.Close()
+	r := c.logMonitoredResource
+	if lookups != 1 || r.Type != "gce_instance" || r.Labels["zone"] != "z" || r.Labels["project_id"] != "p" {
+		t.Fatal("Unexpected resource", lookups, r)
+	}
+	if deadline.IsZero() || time.Until(deadline) > metadataTimeout {
+		t.Fatal("Unexpected deadline", deadline)
+	}
+}
+
+func TestWithResourceLabels(t *testing.T) {
+	t.Setenv("FUNCTION_NAME", "f")
+	t.Setenv("FUNCTION_REGION", "")
+
+	r := newClient(WithProjectID("p"), WithCloudFunctionResource(), WithResourceLabels(map[string]string{"region": "us-central1"})).logMonitoredResource
+	if r.Type != "cloud_function" {
+		t.Fatal("Unexpected type", r.Type)
+	}
+	if r.Labels["region"] != "us-central1" || r.Labels["function_name"] != "f" || r.Labels["project_id"] != "p" {
+		t.Fatal("Unexpected labels", r.Labels)
+	}
+
+	given := &monitoredres.MonitoredResource{Type: "generic_task", Labels: map[string]string{"job": "j"}}
+	r = newClient(WithMonitoredResource(given), WithResourceLabels(map[string]string{"task_id": "t"})).logMonitoredResource
+	if r.Labels["job"] != "j" || r.Labels["task_id"] != "t" || len(given.Labels) != 1 {
+		t.Fatal("Unexpected labels", r.Labels, given.Labels)
+	}
+}
+
+func TestWithResourceType(t *testing.T) {
+	t.Setenv("FUNCTION_NAME", "f")
+
+	r := newClient(WithProjectID("p"), WithResourceType("k8s_container"), WithResourceLabels(map[string]string{"cluster_name": "c"})).logMonitoredResource
+	if r.Type != "k8s_container" {
+		t.Fatal("Unexpected type", r.Type)
+	}
+	if len(r.Labels) != 2 || r.Labels["project_id"] != "p" || r.Labels["cluster_name"] != "c" {
+		t.Fatal("Unexpected labels", r.Labels)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Setenv("FUNCTION_NAME", "f")
+	t.Setenv("FUNCTION_REGION", "")
+
+	tests := []struct {
+		name  string
+		opts  []Option
+		valid bool
+	}{
+		{name: "missing region", opts: []Option{WithProjectID("p"), WithCloudFunctionResource()}},
+		{name: "region set", opts: []Option{WithProjectID("p"), WithCloudFunctionResource(), WithResourceLabels(map[string]string{"region": "r"})}, valid: true},
+		{name: "missing project", opts: []Option{WithProjectID(""), WithMonitoredResource(&monitoredres.MonitoredResource{Type: "global"})}},
+		{name: "unknown type", opts: []Option{WithProjectID("p"), WithMonitoredResource(&monitoredres.MonitoredResource{Type: "generic_task"})}, valid: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := newClient(test.opts...).Validate()
+			if test.valid && err != nil {
+				t.Fatal("Unexpected error", err)
+			}
+			if !test.valid && !errors.Is(err, ErrInvalidResource) {
+				t.Fatal("Expected an invalid resource error", err)
+			}
+		})
+	}
+}
+
+func TestWithMetadataDetection(t *testing.T) {
+	lookup := metadataLookup
+	defer func() { metadataLookup = lookup }()
+	metadataLookup = func() metadataInfo { return metadataInfo{projectID: "meta-project", zone: "us-east1-b"} }
+
+	t.Setenv("GCP_PROJECT", "")
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+	t.Setenv("FUNCTION_NAME", "f")
+	t.Setenv("FUNCTION_REGION", "")
+
+	c := newClient(WithCloudFunctionResource(), WithMetadataDetection())
+	if c.projectID != "meta-project" || c.logName != "projects/meta-project/logs/"+url.PathEscape(defaultLogID) {
+		t.Fatal("Unexpected project", c.projectID, c.logName)
+	}
+	if l := c.logMonitoredResource.Labels; l["region"] != "us-east1" || l["project_id"] != "meta-project" {
+		t.Fatal("Unexpected labels", l)
+	}
+
+	c = newClient(WithCloudFunctionResource(), WithMetadataDetection(), WithResourceLabels(map[string]string{"region": "r"}))
+	if l := c.logMonitoredResource.Labels; l["region"] != "r" {
+		t.Fatal("Expected resource labels to take precedence", l)
+	}
+
+	c = newClient(WithCloudFunctionResource())
+	if c.projectID != "" || c.logMonitoredResource.Labels["region"] != "" {
+		t.Fatal("Expected no detection without the option", c.projectID, c.logMonitoredResource.Labels)
+	}
+}
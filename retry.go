@@ -0,0 +1,47 @@
+package cflog
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// retryable reports whether a failed write may succeed if it is tried again.
+func retryable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// retry calls write until it succeeds, fails with an error that is not retryable, or has been tried
+// maxAttempts times, waiting twice as long after each failure. Cancelling ctx stops the waiting.
+func retry(ctx context.Context, maxAttempts int, baseDelay time.Duration, write func() error) error {
+	delay := baseDelay
+	for attempt := 1; ; attempt++ {
+		err := write()
+		if err == nil || attempt >= maxAttempts || !retryable(err) {
+			return err
+		}
+		// A write cut short by ctx will fail again so surface the cancellation.
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,74 @@
+package cflog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	gax "github.com/googleapis/gax-go/v2"
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestWithRetry(t *testing.T) {
+	ctx := context.Background()
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+
+	c, w := newFakeClient(WithRetry(3, time.Millisecond))
+	w.errs = []error{unavailable, unavailable}
+	if err := c.Info(ctx, "message"); err != nil {
+		t.Fatal("Expected retries to succeed", err)
+	}
+	if len(w.requests) != 1 {
+		t.Fatal("Expected one written request", w.requests)
+	}
+
+	w.errs = []error{unavailable, unavailable, unavailable}
+	if err := c.Info(ctx, "message"); status.Code(err) != codes.Unavailable {
+		t.Fatal("Expected error after the last attempt", err)
+	}
+
+	w.errs = []error{status.Error(codes.InvalidArgument, "invalid"), unavailable}
+	if err := c.Info(ctx, "message"); status.Code(err) != codes.InvalidArgument {
+		t.Fatal("Expected non-retryable error to return immediately", err)
+	}
+	if len(w.errs) != 1 {
+		t.Fatal("Expected no retry", w.errs)
+	}
+}
+
+func TestRetryCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	attempts := 0
+	err := retry(ctx, 5, time.Hour, func() error {
+		attempts++
+		return status.Error(codes.Unavailable, "unavailable")
+	})
+	if err != context.Canceled || attempts != 1 {
+		t.Fatal("Expected cancellation to stop the retries", err, attempts)
+	}
+}
+
+// slowWriter blocks until the context of each write is done.
+type slowWriter struct{ attempts int }
+
+func (w *slowWriter) WriteLogEntries(ctx context.Context, req *loggingpb.WriteLogEntriesRequest, opts ...gax.CallOption) (*loggingpb.WriteLogEntriesResponse, error) {
+	w.attempts++
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestWithWriteTimeout(t *testing.T) {
+	w := &slowWriter{}
+	c := NewClientWithWriter(w, WithWriteTimeout(time.Millisecond), WithRetry(2, time.Millisecond))
+
+	if err := c.Info(context.Background(), "message"); err != context.DeadlineExceeded {
+		t.Fatal("Expected timeout error", err)
+	}
+	if w.attempts != 2 {
+		t.Fatal("Expected the timed out write to be retried", w.attempts)
+	}
+}
@@ -0,0 +1,115 @@
+package cflog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/logging/type"
+)
+
+// Severity is a wrapper around an int for log severity.
+type Severity ltype.LogSeverity
+
+// Using the log packages severity.
+// https://godoc.org/google.golang.org/genproto/googleapis/logging/type#LogSeverity
+const (
+	SeverityDefault   = Severity(ltype.LogSeverity_DEFAULT)
+	SeverityDebug     = Severity(ltype.LogSeverity_DEBUG)
+	SeverityInfo      = Severity(ltype.LogSeverity_INFO)
+	SeverityNotice    = Severity(ltype.LogSeverity_NOTICE)
+	SeverityWarning   = Severity(ltype.LogSeverity_WARNING)
+	SeverityError     = Severity(ltype.LogSeverity_ERROR)
+	SeverityCritical  = Severity(ltype.LogSeverity_CRITICAL)
+	SeverityAlert     = Severity(ltype.LogSeverity_ALERT)
+	SeverityEmergency = Severity(ltype.LogSeverity_EMERGENCY)
+)
+
+// String returns the GCP name of the severity, e.g. "INFO" or "ERROR".
+func (s Severity) String() string {
+	if name, ok := ltype.LogSeverity_name[int32(s)]; ok {
+		return name
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// MoreSevereThan reports whether s is more severe than other.
+// The GCP severity values increase with severity, from SeverityDefault to SeverityEmergency,
+// which is the order this relies on.
+func (s Severity) MoreSevereThan(other Severity) bool {
+	return int32(s) > int32(other)
+}
+
+// severityNames lists the GCP severity names in order for error messages.
+const severityNames = "DEFAULT, DEBUG, INFO, NOTICE, WARNING, ERROR, CRITICAL, ALERT, EMERGENCY"
+
+// severityAliases maps common shorthand names onto the GCP severity names.
+var severityAliases = map[string]string{
+	"WARN":  "WARNING",
+	"ERR":   "ERROR",
+	"CRIT":  "CRITICAL",
+	"EMERG": "EMERGENCY",
+}
+
+// ParseSeverity converts a severity name such as "info" or "WARNING" into a Severity.
+// Matching is case-insensitive and accepts common aliases like "warn".
+func ParseSeverity(s string) (Severity, error) {
+	name := strings.ToUpper(strings.TrimSpace(s))
+	if alias, ok := severityAliases[name]; ok {
+		name = alias
+	}
+	if v, ok := ltype.LogSeverity_value[name]; ok {
+		return Severity(v), nil
+	}
+	return SeverityDefault, fmt.Errorf("unknown severity %q, must be one of %s", s, severityNames)
+}
+
+// MarshalJSON encodes the severity as its GCP name, or as its number when it has no name.
+func (s Severity) MarshalJSON() ([]byte, error) {
+	if _, ok := ltype.LogSeverity_name[int32(s)]; !ok {
+		return []byte(strconv.Itoa(int(s))), nil
+	}
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON decodes a severity name as ParseSeverity does, or a severity number.
+func (s *Severity) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		parsed, err := ParseSeverity(name)
+		if err != nil {
+			return err
+		}
+		*s = parsed
+		return nil
+	}
+	var n int32
+	if err := json.Unmarshal(data, &n); err != nil {
+		return fmt.Errorf("severity must be a name or number: %s", data)
+	}
+	*s = Severity(n)
+	return nil
+}
+
+// MarshalText encodes the severity as its GCP name so it works with flag.TextVar and config packages.
+// Severities without a name return an error.
+func (s Severity) MarshalText() ([]byte, error) {
+	if _, ok := ltype.LogSeverity_name[int32(s)]; !ok {
+		return nil, fmt.Errorf("cannot marshal %s as text", s)
+	}
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a severity name as ParseSeverity does.
+func (s *Severity) UnmarshalText(text []byte) error {
+	parsed, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
@@ -0,0 +1,148 @@
+package cflog
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSeverityString(t *testing.T) {
+	tests := []struct {
+		severity Severity
+		expected string
+	}{
+		{severity: SeverityDefault, expected: "DEFAULT"},
+		{severity: SeverityDebug, expected: "DEBUG"},
+		{severity: SeverityInfo, expected: "INFO"},
+		{severity: SeverityNotice, expected: "NOTICE"},
+		{severity: SeverityWarning, expected: "WARNING"},
+		{severity: SeverityError, expected: "ERROR"},
+		{severity: SeverityCritical, expected: "CRITICAL"},
+		{severity: SeverityAlert, expected: "ALERT"},
+		{severity: SeverityEmergency, expected: "EMERGENCY"},
+		{severity: Severity(42), expected: "Severity(42)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expected, func(t *testing.T) {
+			if s := test.severity.String(); s != test.expected {
+				t.Fatal("Unexpected string", s)
+			}
+		})
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Severity
+	}{
+		{input: "default", expected: SeverityDefault},
+		{input: "debug", expected: SeverityDebug},
+		{input: "Info", expected: SeverityInfo},
+		{input: "NOTICE", expected: SeverityNotice},
+		{input: "warning", expected: SeverityWarning},
+		{input: "warn", expected: SeverityWarning},
+		{input: " error ", expected: SeverityError},
+		{input: "err", expected: SeverityError},
+		{input: "critical", expected: SeverityCritical},
+		{input: "alert", expected: SeverityAlert},
+		{input: "emergency", expected: SeverityEmergency},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			s, err := ParseSeverity(test.input)
+			if err != nil {
+				t.Fatal("Parse error", err)
+			}
+			if s != test.expected {
+				t.Fatal("Unexpected severity", s)
+			}
+		})
+	}
+
+	for _, input := range []string{"", "verbose", "INFOO"} {
+		if _, err := ParseSeverity(input); err == nil {
+			t.Fatalf("Expected error parsing %q", input)
+		}
+	}
+}
+
+func TestMoreSevereThan(t *testing.T) {
+	ordered := []Severity{
+		SeverityDefault, SeverityDebug, SeverityInfo, SeverityNotice, SeverityWarning,
+		SeverityError, SeverityCritical, SeverityAlert, SeverityEmergency,
+	}
+	for i, s := range ordered {
+		for j, other := range ordered {
+			if got := s.MoreSevereThan(other); got != (i > j) {
+				t.Fatal("Unexpected comparison", s, other, got)
+			}
+		}
+	}
+}
+
+func TestSeverityJSON(t *testing.T) {
+	type config struct {
+		Level Severity `json:"level"`
+	}
+
+	data, err := json.Marshal(config{Level: SeverityWarning})
+	if err != nil {
+		t.Fatal("Marshal error", err)
+	}
+	if string(data) != `{"level":"WARNING"}` {
+		t.Fatal("Unexpected JSON", string(data))
+	}
+
+	tests := []struct {
+		input    string
+		expected Severity
+		err      bool
+	}{
+		{input: `{"level":"WARNING"}`, expected: SeverityWarning},
+		{input: `{"level":"error"}`, expected: SeverityError},
+		{input: `{"level":700}`, expected: SeverityAlert},
+		{input: `{"level":"loud"}`, err: true},
+		{input: `{"level":true}`, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			var c config
+			err := json.Unmarshal([]byte(test.input), &c)
+			if test.err {
+				if err == nil {
+					t.Fatal("Expected an error", c.Level)
+				}
+				return
+			}
+			if err != nil || c.Level != test.expected {
+				t.Fatal("Unexpected severity", c.Level, err)
+			}
+		})
+	}
+}
+
+func TestSeverityText(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	var s Severity
+	fs.TextVar(&s, "severity", SeverityInfo, "minimum severity")
+
+	if err := fs.Parse([]string{"-severity", "warn"}); err != nil || s != SeverityWarning {
+		t.Fatal("Unexpected flag value", s, err)
+	}
+	if err := fs.Parse([]string{"-severity", "loud"}); err == nil || !strings.Contains(err.Error(), `unknown severity "loud"`) {
+		t.Fatal("Expected an unknown severity error", err)
+	}
+
+	if text, err := SeverityCritical.MarshalText(); err != nil || string(text) != "CRITICAL" {
+		t.Fatal("Unexpected text", string(text), err)
+	}
+	if _, err := Severity(42).MarshalText(); err == nil {
+		t.Fatal("Expected an error for an unnamed severity")
+	}
+}
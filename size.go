@@ -0,0 +1,114 @@
+package cflog
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"unicode/utf8"
+
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// ErrEntryTooLarge is returned for an entry still over the size set by WithMaxEntrySize after truncation.
+var ErrEntryTooLarge = errors.New("log entry too large")
+
+// ErrLabelTooLong is returned with WithStrictLabels for an entry with a label key or value over its limit.
+var ErrLabelTooLong = errors.New("log entry label too long")
+
+// truncatedMarker ends a text payload or label that was cut to fit its limit.
+const truncatedMarker = "...(truncated)"
+
+// Limits of entry label keys and values.
+// https://cloud.google.com/logging/quotas#log-limits
+const (
+	maxLabelKeyBytes   = 512
+	maxLabelValueBytes = 64 << 10
+)
+
+// fitEntry shrinks an entry over max bytes by cutting its text payload or dropping the largest
+// top level fields of its JSON payload. It returns ErrEntryTooLarge if the entry still does not fit.
+func fitEntry(entry *loggingpb.LogEntry, max int) error {
+	size := proto.Size(protoadapt.MessageV2Of(entry))
+	if size <= max {
+		return nil
+	}
+
+	switch p := entry.Payload.(type) {
+	case *loggingpb.LogEntry_TextPayload:
+		// Leave room for the marker and for the length prefix of the text changing.
+		cut := len(p.TextPayload) - (size - max) - len(truncatedMarker) - binary.MaxVarintLen64
+		for cut > 0 && !utf8.RuneStart(p.TextPayload[cut]) {
+			cut--
+		}
+		if cut > 0 {
+			p.TextPayload = p.TextPayload[:cut] + truncatedMarker
+		}
+	case *loggingpb.LogEntry_JsonPayload:
+		fields := p.JsonPayload.GetFields()
+		for len(fields) > 0 && proto.Size(protoadapt.MessageV2Of(entry)) > max {
+			largest, largestSize := "", -1
+			for k, v := range fields {
+				if s := proto.Size(v); s > largestSize {
+					largest, largestSize = k, s
+				}
+			}
+			delete(fields, largest)
+		}
+	}
+
+	if size := proto.Size(protoadapt.MessageV2Of(entry)); size > max {
+		return fmt.Errorf("%w: %d bytes is over the limit of %d", ErrEntryTooLarge, size, max)
+	}
+	return nil
+}
+
+// fitLabels cuts label keys and values over their limits to end with truncatedMarker.
+// A cut key also gets a hash of the whole key so keys sharing a long prefix stay separate labels.
+// With strict it leaves them and returns ErrLabelTooLong instead.
+func fitLabels(entry *loggingpb.LogEntry, strict bool) error {
+	for k, v := range entry.Labels {
+		if len(k) <= maxLabelKeyBytes && len(v) <= maxLabelValueBytes {
+			continue
+		}
+		if strict {
+			if len(k) > maxLabelKeyBytes {
+				return fmt.Errorf("%w: key %.32q... is %d bytes, over the limit of %d", ErrLabelTooLong, k, len(k), maxLabelKeyBytes)
+			}
+			return fmt.Errorf("%w: value of %q is %d bytes, over the limit of %d", ErrLabelTooLong, k, len(v), maxLabelValueBytes)
+		}
+		delete(entry.Labels, k)
+		key := k
+		if len(k) > maxLabelKeyBytes {
+			key = truncatedKey(k)
+		}
+		entry.Labels[key] = truncate(v, maxLabelValueBytes)
+	}
+	return nil
+}
+
+// truncatedKey cuts a label key to maxLabelKeyBytes ending with a hash of the whole key and truncatedMarker.
+func truncatedKey(k string) string {
+	h := fnv.New32a()
+	h.Write([]byte(k))
+	suffix := fmt.Sprintf("-%08x", h.Sum32())
+	return cut(k, maxLabelKeyBytes-len(suffix)-len(truncatedMarker)) + suffix + truncatedMarker
+}
+
+// truncate cuts s to at most max bytes ending with truncatedMarker, keeping whole runes.
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return cut(s, max-len(truncatedMarker)) + truncatedMarker
+}
+
+// cut returns the longest prefix of s of at most n bytes that keeps whole runes.
+func cut(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
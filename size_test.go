@@ -0,0 +1,84 @@
+package cflog
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMaxEntrySize(t *testing.T) {
+	c, w := newFakeClient(WithMaxEntrySize(200))
+	ctx := context.Background()
+
+	if err := c.Info(ctx, strings.Repeat("a", 500)); err != nil {
+		t.Fatal("Text error", err)
+	}
+	if err := c.Info(ctx, map[string]interface{}{"big": strings.Repeat("b", 500), "small": "s"}); err != nil {
+		t.Fatal("JSON error", err)
+	}
+
+	entries := w.entries()
+	if len(entries) != 2 {
+		t.Fatal("Unexpected entries", entries)
+	}
+	if text := entries[0].GetTextPayload(); !strings.HasSuffix(text, truncatedMarker) || len(text) > 200 {
+		t.Fatal("Unexpected text payload", text)
+	}
+	fields := entries[1].GetJsonPayload().GetFields()
+	if _, ok := fields["big"]; ok || fields["small"].GetStringValue() != "s" {
+		t.Fatal("Unexpected JSON payload", fields)
+	}
+
+	err := c.LogWithLabels(ctx, SeverityInfo, "text", map[string]string{"label": strings.Repeat("l", 500)})
+	if !errors.Is(err, ErrEntryTooLarge) {
+		t.Fatal("Unexpected error", err)
+	}
+}
+
+func TestLabelLimits(t *testing.T) {
+	ctx := context.Background()
+	longKey := strings.Repeat("k", maxLabelKeyBytes+1)
+	longValue := strings.Repeat("é", maxLabelValueBytes)
+	labels := map[string]string{"ok": "v", "value": longValue, longKey: "v"}
+
+	c, w := newFakeClient()
+	if err := c.LogWithLabels(ctx, SeverityInfo, "text", labels); err != nil {
+		t.Fatal("Log error", err)
+	}
+	got := w.entries()[0].Labels
+	if len(got) != 3 || got["ok"] != "v" {
+		t.Fatal("Unexpected labels", len(got))
+	}
+	if v := got["value"]; len(v) > maxLabelValueBytes || !strings.HasSuffix(v, truncatedMarker) || !utf8.ValidString(v) {
+		t.Fatal("Unexpected value", len(v))
+	}
+	key := truncatedKey(longKey)
+	if got[key] != "v" || len(key) > maxLabelKeyBytes || !strings.HasPrefix(key, "kkk") || !strings.HasSuffix(key, truncatedMarker) {
+		t.Fatal("Unexpected key", key)
+	}
+
+	// Keys that only differ past the limit must not overwrite each other.
+	c, w = newFakeClient()
+	if err := c.LogWithLabels(ctx, SeverityInfo, "text", map[string]string{longKey + "a": "a", longKey + "b": "b"}); err != nil {
+		t.Fatal("Log error", err)
+	}
+	got = w.entries()[0].Labels
+	if len(got) != 2 || got[truncatedKey(longKey+"a")] != "a" || got[truncatedKey(longKey+"b")] != "b" {
+		t.Fatal("Unexpected labels", got)
+	}
+
+	c, w = newFakeClient(WithStrictLabels(true))
+	for _, l := range []map[string]string{{"value": longValue}, {longKey: "v"}} {
+		if err := c.LogWithLabels(ctx, SeverityInfo, "text", l); !errors.Is(err, ErrLabelTooLong) {
+			t.Fatal("Unexpected error", err)
+		}
+	}
+	if err := c.LogWithLabels(ctx, SeverityInfo, "text", map[string]string{"ok": "v"}); err != nil {
+		t.Fatal("Log error", err)
+	}
+	if len(w.entries()) != 1 {
+		t.Fatal("Unexpected entries", w.entries())
+	}
+}
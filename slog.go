@@ -0,0 +1,162 @@
+package cflog
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// slogHandler is a slog.Handler that writes records as entries with a Client.
+type slogHandler struct {
+	client Client
+	fields map[string]interface{}
+	groups []string
+}
+
+// NewSlogHandler returns a slog.Handler that writes each record as an entry with a JSON payload.
+// The record message is under the client's message key, "message" by default, and its attributes are fields, nested by group.
+// Levels below Info are Debug, then each step of 4 from Info is Info, Warning, Error, Critical,
+// Alert, and Emergency.
+func NewSlogHandler(c Client) slog.Handler {
+	return &slogHandler{client: c, fields: map[string]interface{}{}}
+}
+
+// Enabled implements slog.Handler and reports whether the client's minimum severity allows the level.
+func (h *slogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return int32(slogSeverity(level)) >= int32(h.client.minSeverity)
+}
+
+// Handle implements slog.Handler.
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	fields := copyFields(h.fields)
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	addAttrs(fields, h.groups, attrs)
+	fields[h.client.messageField()] = r.Message
+
+	return h.client.log(ctx, slogSeverity(r.Level), fields, func(entry *loggingpb.LogEntry) error {
+		if !r.Time.IsZero() {
+			entry.Timestamp = timestamppb.New(r.Time)
+		}
+		if h.client.sourceLocation && r.PC != 0 {
+			// The caller found by walking the stack would be inside slog so use the record's.
+			f, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+			entry.SourceLocation = &loggingpb.LogEntrySourceLocation{File: f.File, Line: int64(f.Line), Function: f.Function}
+		}
+		return nil
+	})
+}
+
+// WithAttrs implements slog.Handler.
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := copyFields(h.fields)
+	addAttrs(fields, h.groups, attrs)
+	return &slogHandler{client: h.client, fields: fields, groups: h.groups}
+}
+
+// WithGroup implements slog.Handler.
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	groups := append(append([]string{}, h.groups...), name)
+	return &slogHandler{client: h.client, fields: h.fields, groups: groups}
+}
+
+// slogSeverity maps a slog level onto a Severity.
+func slogSeverity(l slog.Level) Severity {
+	switch {
+	case l < slog.LevelInfo:
+		return SeverityDebug
+	case l < slog.LevelWarn:
+		return SeverityInfo
+	case l < slog.LevelError:
+		return SeverityWarning
+	case l < slog.LevelError+4:
+		return SeverityError
+	case l < slog.LevelError+8:
+		return SeverityCritical
+	case l < slog.LevelError+12:
+		return SeverityAlert
+	}
+	return SeverityEmergency
+}
+
+// groupFields returns the map for the nested groups inside fields, creating it as needed.
+func groupFields(fields map[string]interface{}, groups []string) map[string]interface{} {
+	for _, g := range groups {
+		nested, ok := fields[g].(map[string]interface{})
+		if !ok {
+			nested = map[string]interface{}{}
+			fields[g] = nested
+		}
+		fields = nested
+	}
+	return fields
+}
+
+// addAttrs adds attributes to the nested groups of fields.
+// Groups are only created when they end up holding an attribute.
+func addAttrs(fields map[string]interface{}, groups []string, attrs []slog.Attr) {
+	added := map[string]interface{}{}
+	for _, a := range attrs {
+		addAttr(added, a)
+	}
+	if len(added) == 0 {
+		return
+	}
+	group := groupFields(fields, groups)
+	for k, v := range added {
+		group[k] = v
+	}
+}
+
+// addAttr adds an attribute to fields following the slog.Handler rules for empty and group attributes.
+func addAttr(fields map[string]interface{}, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		fields[a.Key] = slogValue(a.Value)
+		return
+	}
+
+	attrs := a.Value.Group()
+	if len(attrs) == 0 {
+		return
+	}
+	group := fields
+	if a.Key != "" {
+		group = groupFields(fields, []string{a.Key})
+	}
+	for _, ga := range attrs {
+		addAttr(group, ga)
+	}
+}
+
+// slogValue converts a resolved non-group value into a payload value.
+func slogValue(v slog.Value) interface{} {
+	if err, ok := v.Any().(error); ok {
+		return err.Error()
+	}
+	return v.Any()
+}
+
+// copyFields deep copies the nested group maps of fields so handlers do not share them.
+func copyFields(fields map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		if nested, ok := v.(map[string]interface{}); ok {
+			v = copyFields(nested)
+		}
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,62 @@
+package cflog
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogHandler(t *testing.T) {
+	c, w := newFakeClient()
+	logger := slog.New(NewSlogHandler(c)).With("service", "svc").WithGroup("req").With("id", 7)
+
+	logger.Warn("handled", "status", 200, slog.Group("user", "name", "n"), "err", errors.New("oops"))
+	logger.WithGroup("empty").Info("no attrs")
+
+	entries := w.entries()
+	if len(entries) != 2 {
+		t.Fatal("Unexpected entries", entries)
+	}
+	if Severity(entries[0].Severity) != SeverityWarning {
+		t.Fatal("Unexpected severity", entries[0].Severity)
+	}
+	fields := entries[0].GetJsonPayload().GetFields()
+	if fields["message"].GetStringValue() != "handled" || fields["service"].GetStringValue() != "svc" {
+		t.Fatal("Unexpected fields", fields)
+	}
+	req := fields["req"].GetStructValue().GetFields()
+	if req["id"].GetNumberValue() != 7 || req["status"].GetNumberValue() != 200 || req["err"].GetStringValue() != "oops" {
+		t.Fatal("Unexpected group fields", req)
+	}
+	if n := req["user"].GetStructValue().GetFields()["name"].GetStringValue(); n != "n" {
+		t.Fatal("Unexpected nested group", req)
+	}
+	if entries[0].Timestamp == nil {
+		t.Fatal("Expected record time")
+	}
+
+	if _, ok := entries[1].GetJsonPayload().GetFields()["req"].GetStructValue().GetFields()["empty"]; ok {
+		t.Fatal("Expected empty group to be left out", entries[1].GetJsonPayload())
+	}
+}
+
+func TestSlogSeverity(t *testing.T) {
+	tests := []struct {
+		level    slog.Level
+		expected Severity
+	}{
+		{level: slog.LevelDebug, expected: SeverityDebug},
+		{level: slog.LevelInfo, expected: SeverityInfo},
+		{level: slog.LevelWarn, expected: SeverityWarning},
+		{level: slog.LevelError, expected: SeverityError},
+		{level: slog.LevelError + 4, expected: SeverityCritical},
+		{level: slog.LevelError + 8, expected: SeverityAlert},
+		{level: slog.LevelError + 12, expected: SeverityEmergency},
+	}
+
+	for _, test := range tests {
+		if s := slogSeverity(test.level); s != test.expected {
+			t.Fatalf("Unexpected severity for %v: %v", test.level, s)
+		}
+	}
+}
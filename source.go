@@ -0,0 +1,39 @@
+package cflog
+
+import (
+	"runtime"
+	"strings"
+
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+)
+
+// packagePrefix prefixes the names of functions in this package.
+const packagePrefix = "github.com/mvndaai/cflog."
+
+// callerLocation returns the source location of the first caller outside of this package.
+// Walking the stack rather than skipping a fixed count keeps it correct through any of the
+// severity helpers and the singleton wrappers. After leaving this package, frames of functions
+// starting with skip, such as "log." for the standard logger, are passed over, and then depth more,
+// for adapters like logr that know how many of their own frames are above them.
+func callerLocation(skip string, depth int) *loggingpb.LogEntrySourceLocation {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	inside := true
+	for {
+		f, more := frames.Next()
+		switch {
+		case inside && strings.HasPrefix(f.Function, packagePrefix):
+		case skip != "" && strings.HasPrefix(f.Function, skip):
+			inside = false
+		case depth > 0:
+			inside = false
+			depth--
+		default:
+			return &loggingpb.LogEntrySourceLocation{File: f.File, Line: int64(f.Line), Function: f.Function}
+		}
+		if !more {
+			return nil
+		}
+	}
+}
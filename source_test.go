@@ -0,0 +1,81 @@
+package cflog_test
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-logr/logr"
+	gax "github.com/googleapis/gax-go/v2"
+	"github.com/mvndaai/cflog"
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+)
+
+// entryRecorder is an EntryWriter that keeps the entries written to it.
+type entryRecorder struct {
+	mu      sync.Mutex
+	entries []*loggingpb.LogEntry
+}
+
+func (r *entryRecorder) WriteLogEntries(ctx context.Context, req *loggingpb.WriteLogEntriesRequest, opts ...gax.CallOption) (*loggingpb.WriteLogEntriesResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, req.Entries...)
+	return &loggingpb.WriteLogEntriesResponse{}, nil
+}
+
+func (r *entryRecorder) last() *loggingpb.LogEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entries) == 0 {
+		return nil
+	}
+	return r.entries[len(r.entries)-1]
+}
+
+// logHelper wraps a logr.Logger the way helpers do, so its own frame is skipped.
+func logHelper(l logr.Logger, msg string) {
+	l.WithCallDepth(1).Info(msg)
+}
+
+func TestSourceLocation(t *testing.T) {
+	ctx := context.Background()
+	r := &entryRecorder{}
+	c := cflog.NewClientWithWriter(r, cflog.WithProjectID("p"), cflog.WithSourceLocation(true))
+	l := logr.New(cflog.NewLogSink(c))
+
+	tests := []struct {
+		name string
+		log  func()
+	}{
+		{name: "log", log: func() { c.Info(ctx, "message") }},
+		{name: "writer", log: func() { log.New(c.Writer(cflog.SeverityInfo), "", 0).Printf("message") }},
+		{name: "logr", log: func() { l.Info("message") }},
+		{name: "logr error", log: func() { l.Error(nil, "message") }},
+		{name: "logr helper", log: func() { logHelper(l, "message") }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.log()
+			entry := r.last()
+			if entry == nil {
+				t.Fatal("Expected an entry")
+			}
+			loc := entry.SourceLocation
+			if loc == nil || !strings.HasSuffix(loc.File, "source_test.go") || !strings.HasPrefix(loc.Function, "github.com/mvndaai/cflog_test.TestSourceLocation") {
+				t.Fatal("Unexpected location", loc)
+			}
+		})
+	}
+
+	entry, err := cflog.NewClientWithWriter(r).BuildEntry(ctx, cflog.SeverityInfo, "message")
+	if err != nil {
+		t.Fatal("Entry error", err)
+	}
+	if entry.SourceLocation != nil {
+		t.Fatal("Expected no location", entry.SourceLocation)
+	}
+}
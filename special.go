@@ -0,0 +1,58 @@
+package cflog
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/genproto/googleapis/logging/type"
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Special JSON payload fields Cloud Logging maps onto entry fields for logs written to stdout.
+// https://cloud.google.com/logging/docs/structured-logging#special-payload-fields
+const (
+	specialSeverity     = "severity"
+	specialTrace        = "logging.googleapis.com/trace"
+	specialSpanID       = "logging.googleapis.com/spanId"
+	specialTraceSampled = "logging.googleapis.com/trace_sampled"
+)
+
+// promoteSpecialFields moves the special fields of a JSON payload onto the entry the way Cloud Logging
+// does for stdout. Fields with values of the wrong type, or a severity that does not parse, stay in the payload.
+// A payload left with only a string message, under the client's message key, becomes a text payload.
+func (c Client) promoteSpecialFields(entry *loggingpb.LogEntry) {
+	payload := entry.GetJsonPayload()
+	fields := payload.GetFields()
+	if len(fields) == 0 {
+		return
+	}
+
+	if v, ok := fields[specialSeverity]; ok {
+		if s, err := ParseSeverity(v.GetStringValue()); err == nil {
+			entry.Severity = ltype.LogSeverity(s)
+			delete(fields, specialSeverity)
+		}
+	}
+	if v, ok := fields[specialTrace]; ok && v.GetStringValue() != "" {
+		entry.Trace = v.GetStringValue()
+		if !strings.HasPrefix(entry.Trace, "projects/") {
+			entry.Trace = fmt.Sprintf("projects/%s/traces/%s", c.projectID, entry.Trace)
+		}
+		delete(fields, specialTrace)
+	}
+	if v, ok := fields[specialSpanID]; ok && v.GetStringValue() != "" {
+		entry.SpanId = v.GetStringValue()
+		delete(fields, specialSpanID)
+	}
+	if v, ok := fields[specialTraceSampled].GetKind().(*structpb.Value_BoolValue); ok {
+		entry.TraceSampled = v.BoolValue
+		delete(fields, specialTraceSampled)
+	}
+
+	if len(fields) == 1 {
+		if msg, ok := fields[c.messageField()].GetKind().(*structpb.Value_StringValue); ok {
+			entry.Payload = &loggingpb.LogEntry_TextPayload{TextPayload: msg.StringValue}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package cflog
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSpecialFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		severity Severity
+		trace    string
+		spanID   string
+		sampled  bool
+		text     string
+		json     string
+	}{
+		{
+			name:     "severity and message",
+			payload:  `{"severity":"error","message":"boom"}`,
+			severity: SeverityError,
+			text:     "boom",
+		},
+		{
+			name:     "trace id",
+			payload:  `{"message":"hi","logging.googleapis.com/trace":"abc","logging.googleapis.com/spanId":"0001","logging.googleapis.com/trace_sampled":true,"a":1}`,
+			severity: SeverityInfo,
+			trace:    "projects/p/traces/abc",
+			spanID:   "0001",
+			sampled:  true,
+			json:     `{"a":1,"message":"hi"}`,
+		},
+		{
+			name:     "trace resource name",
+			payload:  `{"message":"hi","logging.googleapis.com/trace":"projects/other/traces/abc"}`,
+			severity: SeverityInfo,
+			trace:    "projects/other/traces/abc",
+			text:     "hi",
+		},
+		{
+			name:     "unknown severity",
+			payload:  `{"severity":"loud","message":"hi"}`,
+			severity: SeverityInfo,
+			json:     `{"message":"hi","severity":"loud"}`,
+		},
+		{
+			name:     "wrong types",
+			payload:  `{"severity":3,"logging.googleapis.com/trace_sampled":"yes","message":1}`,
+			severity: SeverityInfo,
+			json:     `{"logging.googleapis.com/trace_sampled":"yes","message":1,"severity":3}`,
+		},
+	}
+
+	c, _ := newFakeClient(WithSpecialFields(), WithProjectID("p"))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			entry, err := c.BuildEntry(context.Background(), SeverityInfo, test.payload)
+			if err != nil {
+				t.Fatal("Build error", err)
+			}
+			if Severity(entry.Severity) != test.severity {
+				t.Fatal("Unexpected severity", entry.Severity)
+			}
+			if entry.Trace != test.trace || entry.SpanId != test.spanID || entry.TraceSampled != test.sampled {
+				t.Fatal("Unexpected trace", entry.Trace, entry.SpanId, entry.TraceSampled)
+			}
+			if entry.GetTextPayload() != test.text {
+				t.Fatal("Unexpected text payload", entry.GetTextPayload())
+			}
+			if test.json != "" {
+				if got := payloadJSON(t, entry.GetJsonPayload()); got != test.json {
+					t.Fatal("Unexpected payload", got)
+				}
+			}
+		})
+	}
+}
+
+func TestSpecialFieldsOptIn(t *testing.T) {
+	c, _ := newFakeClient()
+	entry, err := c.BuildEntry(context.Background(), SeverityInfo, `{"severity":"error","message":"boom"}`)
+	if err != nil {
+		t.Fatal("Build error", err)
+	}
+	if Severity(entry.Severity) != SeverityInfo {
+		t.Fatal("Unexpected severity", entry.Severity)
+	}
+	if got := payloadJSON(t, entry.GetJsonPayload()); got != `{"message":"boom","severity":"error"}` {
+		t.Fatal("Unexpected payload", got)
+	}
+}
+
+func TestSpecialFieldsTracePrecedence(t *testing.T) {
+	c, _ := newFakeClient(WithSpecialFields(), WithProjectID("p"))
+	ctx := ContextWithTrace(context.Background(), Trace{TraceID: "ctx", SpanID: "ctxspan"})
+
+	entry, err := c.BuildEntry(ctx, SeverityInfo, `{"logging.googleapis.com/trace":"payload","a":1}`)
+	if err != nil {
+		t.Fatal("Build error", err)
+	}
+	if entry.Trace != "projects/p/traces/payload" || entry.SpanId != "" {
+		t.Fatal("Unexpected trace", entry.Trace, entry.SpanId)
+	}
+
+	entry, err = c.BuildEntry(ctx, SeverityInfo, `{"a":1}`)
+	if err != nil {
+		t.Fatal("Build error", err)
+	}
+	if entry.Trace != "projects/p/traces/ctx" {
+		t.Fatal("Unexpected trace", entry.Trace)
+	}
+}
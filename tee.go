@@ -0,0 +1,27 @@
+package cflog
+
+import (
+	"context"
+	"errors"
+)
+
+// multiLogger is a Logger that writes each entry with every logger it holds.
+type multiLogger []Logger
+
+// Tee returns a Logger that writes each entry with every logger given, in order, such as clients
+// for an old and a new log name during a migration or a client and a WithOutput client for comparing output.
+// Every logger is called even when one fails, and their errors are joined with errors.Join.
+func Tee(loggers ...Logger) Logger {
+	return multiLogger(append([]Logger{}, loggers...))
+}
+
+// Log implements Logger.
+func (m multiLogger) Log(ctx context.Context, severity Severity, payload interface{}) error {
+	var errs []error
+	for _, l := range m {
+		if err := l.Log(ctx, severity, payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,45 @@
+package cflog
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type failingLogger struct{ err error }
+
+func (l failingLogger) Log(ctx context.Context, severity Severity, payload interface{}) error {
+	return l.err
+}
+
+func TestTee(t *testing.T) {
+	old, oldW := newFakeClient(WithLogName("old"))
+	next, nextW := newFakeClient(WithLogName("new"))
+
+	if err := Tee(old, next).Log(context.Background(), SeverityInfo, "hi"); err != nil {
+		t.Fatal("Log error", err)
+	}
+	for _, w := range []*fakeWriter{oldW, nextW} {
+		entries := w.entries()
+		if len(entries) != 1 || entries[0].GetTextPayload() != "hi" {
+			t.Fatal("Unexpected entries", entries)
+		}
+	}
+}
+
+func TestTeeErrors(t *testing.T) {
+	errA, errB := errors.New("a"), errors.New("b")
+	c, w := newFakeClient()
+
+	err := Tee(failingLogger{errA}, c, failingLogger{errB}).Log(context.Background(), SeverityInfo, "hi")
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Fatal("Unexpected error", err)
+	}
+	if len(w.entries()) != 1 {
+		t.Fatal("Unexpected entries", w.entries())
+	}
+
+	if err := Tee().Log(context.Background(), SeverityInfo, "hi"); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+}
@@ -0,0 +1,95 @@
+package cflog
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+)
+
+// TraceHeader is the header Google Cloud uses to propagate trace context.
+const TraceHeader = "X-Cloud-Trace-Context"
+
+// Trace identifies the Cloud Trace span a log entry belongs to.
+type Trace struct {
+	TraceID string
+	SpanID  string
+	Sampled bool
+}
+
+// ParseTraceHeader parses an X-Cloud-Trace-Context header value of the form "TRACE_ID/SPAN_ID;o=TRACE_TRUE".
+// A malformed header returns an empty Trace.
+// https://cloud.google.com/trace/docs/setup#force-trace
+func ParseTraceHeader(header string) Trace {
+	value, options := header, ""
+	if i := strings.Index(header, ";"); i >= 0 {
+		value, options = header[:i], header[i+1:]
+	}
+	traceID, spanID := value, ""
+	if i := strings.Index(value, "/"); i >= 0 {
+		traceID, spanID = value[:i], value[i+1:]
+	}
+	if !isHex(traceID) {
+		return Trace{}
+	}
+
+	t := Trace{TraceID: traceID, Sampled: options == "o=1"}
+	// The header has a decimal span ID but log entries expect 16 hex characters.
+	if span, err := strconv.ParseUint(spanID, 10, 64); err == nil && span != 0 {
+		t.SpanID = fmt.Sprintf("%016x", span)
+	}
+	return t
+}
+
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// TraceExtractor finds the trace a log written with the context belongs to.
+// It returns an empty Trace when there is none.
+type TraceExtractor func(ctx context.Context) Trace
+
+type traceKey struct{}
+
+// ContextWithTrace returns a copy of ctx carrying the trace so Log attaches it to entries.
+func ContextWithTrace(ctx context.Context, t Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// TraceFromContext returns the trace stored by ContextWithTrace.
+// It is the default TraceExtractor of a Client.
+func TraceFromContext(ctx context.Context) Trace {
+	t, _ := ctx.Value(traceKey{}).(Trace)
+	return t
+}
+
+// LogWithTrace creates a log like Log grouped under the trace from an X-Cloud-Trace-Context header value.
+// The header takes precedence over any trace found in the context.
+func (c Client) LogWithTrace(ctx context.Context, severity Severity, payload interface{}, traceHeader string) error {
+	return c.log(ctx, severity, payload, func(entry *loggingpb.LogEntry) error {
+		if t := ParseTraceHeader(traceHeader); t.TraceID != "" {
+			c.setEntryTrace(entry, t)
+		}
+		return nil
+	})
+}
+
+// setEntryTrace sets the trace fields of an entry, leaving them empty for an empty trace.
+func (c Client) setEntryTrace(entry *loggingpb.LogEntry, t Trace) {
+	if t.TraceID == "" {
+		return
+	}
+	entry.Trace = fmt.Sprintf("projects/%s/traces/%s", c.projectID, t.TraceID)
+	entry.SpanId = t.SpanID
+	entry.TraceSampled = t.Sampled
+}
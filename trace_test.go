@@ -0,0 +1,68 @@
+package cflog
+
+import (
+	"context"
+	"testing"
+
+	loggingpb "google.golang.org/genproto/googleapis/logging/v2"
+)
+
+func TestParseTraceHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected Trace
+	}{
+		{name: "full", header: "105445aa7843bc8bf206b12000100000/1;o=1", expected: Trace{TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "0000000000000001", Sampled: true}},
+		{name: "not sampled", header: "105445aa7843bc8bf206b12000100000/255;o=0", expected: Trace{TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "00000000000000ff"}},
+		{name: "no options", header: "105445aa7843bc8bf206b12000100000/255", expected: Trace{TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "00000000000000ff"}},
+		{name: "trace only", header: "105445aa7843bc8bf206b12000100000", expected: Trace{TraceID: "105445aa7843bc8bf206b12000100000"}},
+		{name: "bad span", header: "105445aa7843bc8bf206b12000100000/abc;o=1", expected: Trace{TraceID: "105445aa7843bc8bf206b12000100000", Sampled: true}},
+		{name: "empty", header: "", expected: Trace{}},
+		{name: "bad trace", header: "not-a-trace/1;o=1", expected: Trace{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if tr := ParseTraceHeader(test.header); tr != test.expected {
+				t.Fatalf("Unexpected trace %+v", tr)
+			}
+		})
+	}
+}
+
+func TestSetEntryTrace(t *testing.T) {
+	c := newClient(WithProjectID("p"))
+
+	entry := &loggingpb.LogEntry{}
+	c.setEntryTrace(entry, Trace{TraceID: "abc", SpanID: "0000000000000001"})
+	if entry.Trace != "projects/p/traces/abc" || entry.SpanId != "0000000000000001" {
+		t.Fatal("Unexpected trace", entry.Trace, entry.SpanId)
+	}
+
+	entry = &loggingpb.LogEntry{}
+	c.setEntryTrace(entry, Trace{})
+	if entry.Trace != "" || entry.SpanId != "" {
+		t.Fatal("Expected empty trace", entry.Trace, entry.SpanId)
+	}
+}
+
+func TestTraceExtractor(t *testing.T) {
+	ctx := ContextWithTrace(context.Background(), Trace{TraceID: "abc", SpanID: "0000000000000001"})
+
+	entry, err := newClient(WithProjectID("p")).BuildEntry(ctx, SeverityInfo, "message")
+	if err != nil {
+		t.Fatal("Entry error", err)
+	}
+	if entry.Trace != "projects/p/traces/abc" {
+		t.Fatal("Unexpected trace", entry.Trace)
+	}
+
+	entry, err = newClient(WithTraceExtractor(nil)).BuildEntry(ctx, SeverityInfo, "message")
+	if err != nil {
+		t.Fatal("Entry error", err)
+	}
+	if entry.Trace != "" {
+		t.Fatal("Expected no trace", entry.Trace)
+	}
+}
@@ -0,0 +1,104 @@
+package cflog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// severityWriter is an io.Writer that logs each write at a fixed severity.
+type severityWriter struct {
+	client   Client
+	severity Severity
+}
+
+// Writer returns an io.Writer that logs each write as an entry at the severity given.
+// Trailing newlines are trimmed and JSON is detected like any other payload, so it can
+// be used as the output of the standard log package:
+//
+//	log.SetOutput(c.Writer(cflog.SeverityInfo))
+func (c Client) Writer(severity Severity) io.Writer {
+	// The source location is where the standard logger was called rather than inside it.
+	c.callerSkip = "log."
+	return severityWriter{client: c, severity: severity}
+}
+
+func (w severityWriter) Write(p []byte) (int, error) {
+	// The payload is copied into a string so p is not held onto after returning,
+	// as an io.Writer must not retain p and asynchronous writes happen later.
+	msg := string(bytes.TrimRight(p, "\r\n"))
+	if err := w.client.Log(context.Background(), w.severity, msg); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// jsonWriter is an io.Writer that logs each line of JSON written with the severity from one of its fields.
+type jsonWriter struct {
+	client   Client
+	levelKey string
+}
+
+// JSONWriter returns an io.Writer for the output of JSON line loggers like zap and zerolog.
+// Each line is logged as an entry with the line's fields as its JSON payload, except for the field
+// levelKey, such as "level", which sets the severity. Level names are parsed like ParseSeverity, along
+// with "trace" as Debug, "dpanic" as Critical, "panic" as Alert, and "fatal" as Emergency.
+// A "msg" field is moved to the client's message key, "message" by default.
+// Lines that are not JSON objects are logged as text at Default.
+//
+//	zerolog.New(c.JSONWriter("level"))
+func (c Client) JSONWriter(levelKey string) io.Writer {
+	return jsonWriter{client: c, levelKey: levelKey}
+}
+
+// loggerLevels maps level names used by zap and zerolog that ParseSeverity does not know.
+var loggerLevels = map[string]Severity{
+	"trace":  SeverityDebug,
+	"dpanic": SeverityCritical,
+	"panic":  SeverityAlert,
+	"fatal":  SeverityEmergency,
+}
+
+func (w jsonWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(p, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if err := w.writeLine(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w jsonWriter) writeLine(line []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	// Numbers are kept as written so large integers keep their precision.
+	dec.UseNumber()
+	var fields map[string]interface{}
+	if dec.Decode(&fields) != nil || fields == nil {
+		return w.client.Log(context.Background(), SeverityDefault, string(line))
+	}
+
+	severity := SeverityDefault
+	if level, ok := fields[w.levelKey].(string); ok {
+		delete(fields, w.levelKey)
+		if s, ok := loggerLevels[strings.ToLower(level)]; ok {
+			severity = s
+		} else if s, err := ParseSeverity(level); err == nil {
+			severity = s
+		}
+	}
+	if msg, ok := fields["msg"]; ok {
+		if key := w.client.messageField(); key != "msg" {
+			if _, ok := fields[key]; !ok {
+				fields[key] = msg
+				delete(fields, "msg")
+			}
+		}
+	}
+	return w.client.Log(context.Background(), severity, fields)
+}
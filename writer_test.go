@@ -0,0 +1,81 @@
+package cflog
+
+import (
+	"log"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	c, w := newFakeClient()
+	l := log.New(c.Writer(SeverityNotice), "", 0)
+
+	l.Println("plain text")
+	l.Print(`{"k": "v"}`)
+
+	entries := w.entries()
+	if len(entries) != 2 {
+		t.Fatal("Unexpected entries", entries)
+	}
+	if p := entries[0].GetTextPayload(); p != "plain text" {
+		t.Fatalf("Unexpected payload %q", p)
+	}
+	if Severity(entries[0].Severity) != SeverityNotice {
+		t.Fatal("Unexpected severity", entries[0].Severity)
+	}
+	if v := entries[1].GetJsonPayload().GetFields()["k"].GetStringValue(); v != "v" {
+		t.Fatal("Expected JSON payload", entries[1].Payload)
+	}
+}
+
+func TestWriterReusedBuffer(t *testing.T) {
+	c, w := newFakeClient(WithAsync(10))
+	defer c.Close()
+
+	buf := []byte("first\n")
+	if _, err := c.Writer(SeverityInfo).Write(buf); err != nil {
+		t.Fatal("Write error", err)
+	}
+	copy(buf, "XXXXX")
+	if err := c.Flush(); err != nil {
+		t.Fatal("Flush error", err)
+	}
+	if entries := w.entries(); len(entries) != 1 || entries[0].GetTextPayload() != "first" {
+		t.Fatal("Unexpected entries", entries)
+	}
+}
+
+func TestJSONWriter(t *testing.T) {
+	c, w := newFakeClient()
+	out := c.JSONWriter("level")
+
+	lines := `{"level":"warn","msg":"slow","ms":1200}` + "\n" + `{"level":"fatal","message":"down"}` + "\n"
+	if n, err := out.Write([]byte(lines)); err != nil || n != len(lines) {
+		t.Fatal("Write error", n, err)
+	}
+	if _, err := out.Write([]byte("not json\n")); err != nil {
+		t.Fatal("Write error", err)
+	}
+
+	entries := w.entries()
+	if len(entries) != 3 {
+		t.Fatal("Unexpected entries", entries)
+	}
+	tests := []struct {
+		severity Severity
+		expected string
+	}{
+		{severity: SeverityWarning, expected: `{"message":"slow","ms":1200}`},
+		{severity: SeverityEmergency, expected: `{"message":"down"}`},
+	}
+	for i, test := range tests {
+		if s := Severity(entries[i].Severity); s != test.severity {
+			t.Fatal("Unexpected severity", i, s)
+		}
+		if got := payloadJSON(t, entries[i].GetJsonPayload()); got != test.expected {
+			t.Fatal("Unexpected payload", i, got)
+		}
+	}
+	if Severity(entries[2].Severity) != SeverityDefault || entries[2].GetTextPayload() != "not json" {
+		t.Fatal("Unexpected text entry", entries[2])
+	}
+}